@@ -1,23 +1,32 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/kazhuravlev/toolset/internal/changelog"
+	"github.com/kazhuravlev/toolset/internal/diff"
 	"github.com/kazhuravlev/toolset/internal/humanize"
+	"github.com/kazhuravlev/toolset/internal/shellenv"
 	"github.com/kazhuravlev/toolset/internal/timeh"
 	"github.com/kazhuravlev/toolset/internal/toolversion"
 
 	"github.com/kazhuravlev/optional"
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/jsonschema"
+	"github.com/kazhuravlev/toolset/internal/specfile"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/kazhuravlev/toolset/internal/workdir"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
 	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
 	cli "github.com/urfave/cli/v2"
 )
 
@@ -27,6 +36,18 @@ const (
 	keyInclude  = "include"
 	keyTags     = "tags"
 	keyUnused   = "unused"
+	keyDryRun   = "dry-run"
+	keyOffline  = "offline"
+	keyNotes    = "notes"
+	keyNotesOut = "notes-file"
+	keyOutput   = "output"
+	keyReason   = "reason"
+	keyProfile  = "profile"
+	keyAll      = "all"
+	keyLocal    = "local"
+	keyProject  = "project"
+	keyShell    = "shell"
+	keyWithPath = "with-tools"
 )
 
 var flagParallel = &cli.IntFlag{
@@ -36,10 +57,45 @@ var flagParallel = &cli.IntFlag{
 	Value:   4,
 }
 
+var flagAll = &cli.BoolFlag{
+	Name:  keyAll,
+	Usage: "run for the workspace root and all its members",
+	Value: false,
+}
+
+var flagLocal = &cli.BoolFlag{
+	Name:  keyLocal,
+	Usage: "write tool into untracked .toolset.local.json instead of the project spec",
+	Value: false,
+}
+
 func main() {
 	app := &cli.App{
 		Name:  "toolset",
 		Usage: "Manage local toolsets",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  keyDryRun,
+				Usage: "show changes of spec and lock files and planned installs/removals without touching disk",
+				Value: false,
+			},
+			&cli.BoolFlag{
+				Name:    keyOffline,
+				Usage:   "do not access network for includes. All includes are served from cache",
+				Value:   false,
+				EnvVars: []string{workdir.EnvOffline},
+			},
+			&cli.StringFlag{
+				Name:  keyProject,
+				Usage: "path to the project dir. Spec is searched from this dir instead of the current one",
+				Value: ".",
+			},
+			&cli.StringFlag{
+				Name:    keyProfile,
+				Usage:   "select a profile from spec, like dev or ci",
+				EnvVars: []string{workdir.EnvProfile},
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "version",
@@ -81,12 +137,16 @@ Optionally copy tools from an existing toolset:
 
 	$ toolset add <RUNTIME> <TOOL>
 	$ toolset add go 				github.com/golangci/golangci-lint/cmd/golangci-lint@v1.61.0
+	$ toolset add --local go github.com/go-delve/delve/cmd/dlv@latest
 
 At this point tool will not be installed. In order to install added tool please run
 
-	$ toolset sync`,
+	$ toolset sync
+
+Use --local to add a personal tool (or override a version) in untracked .toolset.local.json.`,
 				Action: withWorkdir(cmdAdd),
 				Flags: []cli.Flag{
+					flagLocal,
 					&cli.StringFlag{
 						Name:     keyCopyFrom,
 						Usage:    "specify addr to source file that will be copied into current config",
@@ -115,11 +175,14 @@ Updates .toolset.lock.json with installed versions.
 	$ toolset sync
 	$ toolset sync --parallel=8
 	$ toolset sync --tags=linters,formatters
+	$ toolset sync --all
 
-When cache is configured, sync automatically downloads from cache and uploads new builds.`,
-				Action: withWorkdir(cmdSync),
+When cache is configured, sync automatically downloads from cache and uploads new builds.
+Use --all to sync the workspace root and all its members.`,
+				Action: withWorkdir(withWorkspace(cmdSync)),
 				Flags: []cli.Flag{
 					flagParallel,
+					flagAll,
 					&cli.StringSliceFlag{
 						Name:     keyTags,
 						Usage:    "filter tools by tags",
@@ -136,10 +199,110 @@ The tool must be added and synced before running.
 	$ toolset run golangci-lint --version
 	$ toolset run gofumpt -l -w .
 	$ toolset run <tool-name> [args...]
+	$ toolset run --with-tools buf generate
 
-If tool is not added, run 'toolset add'. If not installed, run 'toolset sync'.`,
+If tool is not added, run 'toolset add'. If not installed, run 'toolset sync'.
+Use --with-tools when the tool calls other tools (like buf with protoc plugins): binaries of all installed
+tools are placed first on its PATH.`,
 				Action: withWorkdir(cmdRun),
 				Args:   true,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  keyWithPath,
+						Usage: "put binaries of all installed tools first on PATH of the tool",
+						Value: false,
+					},
+					&cli.StringSliceFlag{
+						Name:     keyTags,
+						Usage:    "filter tools that are placed on PATH by tags",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:      "exec",
+				Usage:     "run a command with all project tools on PATH",
+				ArgsUsage: "-- <command> [args...]",
+				Description: `Run an arbitrary command with binaries of all installed tools placed first on PATH.
+The command and all its children see exactly the locked versions of tools.
+
+	$ toolset exec -- go generate ./...
+	$ toolset exec --tags=codegen -- make proto`,
+				Action: withWorkdir(cmdExec),
+				Args:   true,
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     keyTags,
+						Usage:    "filter tools by tags",
+						Required: false,
+					},
+				},
+			},
+			{
+				Name:  "shims",
+				Usage: "write shims of project tools into .toolset/bin",
+				Description: `Write a small shim for every tool into .toolset/bin of the project. Shim runs the
+locked version of the tool by 'toolset run', so tools can be called directly by IDEs, Makefiles and git hooks.
+Once created, shims are refreshed by 'toolset sync'.
+
+	$ toolset shims
+	$ export PATH="$PWD/.toolset/bin:$PATH"
+
+Set TOOLSET_BIN when toolset is not available on PATH.`,
+				Action: withWorkdir(cmdShims),
+			},
+			{
+				Name:  "env",
+				Usage: "print shell commands that put project tools on PATH",
+				Description: `Print commands that place the shims dir of the project first on PATH. Shims run tools
+by 'toolset run', so tools are called with their aliases, env and args. Create shims once by 'toolset shims'.
+Dirs of the previous activation are removed, so the output can be evaluated on every directory change. Outside of a
+project it only removes previously added dirs. The command only reads the project location and writes nothing.
+
+	$ eval "$(toolset env --shell bash)"
+	$ toolset env --shell fish | source
+
+Use 'toolset hook' to re-evaluate it automatically on cd.`,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  keyShell,
+						Usage: "shell: bash, zsh or fish",
+						Value: string(shellenv.ShellBash),
+					},
+				},
+				Action: cmdEnv,
+			},
+			{
+				Name:      "hook",
+				Usage:     "print a shell hook that activates project tools on cd",
+				ArgsUsage: "bash|zsh|fish|direnv",
+				Description: `Print a script that runs 'toolset env' every time the current dir is changed.
+Add it to your shell config:
+
+	# ~/.bashrc
+	eval "$(toolset hook bash)"
+	# ~/.zshrc
+	eval "$(toolset hook zsh)"
+	# ~/.config/fish/config.fish
+	toolset hook fish | source
+
+For direnv, add the 'use_toolset' helper to ~/.config/direnv/direnvrc and call 'use toolset' in .envrc:
+
+	$ toolset hook direnv >> ~/.config/direnv/direnvrc`,
+				Action: cmdHook,
+			},
+			{
+				Name:  "task",
+				Usage: "run a script from spec",
+				Description: `Run a named script from the "scripts" section of spec. Each script is a sequence
+(or a parallel group) of tool runs and other scripts. Tools are installed before the first step.
+Without arguments prints a list of scripts.
+
+	$ toolset task
+	$ toolset task lint`,
+				Action:    withWorkdir(cmdTask),
+				Args:      true,
+				ArgsUsage: "[name]",
 			},
 			{
 				Name:  "upgrade",
@@ -151,6 +314,8 @@ Updates .toolset.json with new versions and installs them.
 	$ toolset upgrade golangci-lint
 	$ toolset upgrade --tags=linters
 	$ toolset upgrade --parallel=8
+	$ toolset upgrade --notes
+	$ toolset upgrade --notes-file=CHANGELOG.md
 
 Upgrades all tools by default. Specify a module name or use --tags to filter.`,
 				Action: withWorkdir(cmdUpgrade),
@@ -161,6 +326,112 @@ Upgrades all tools by default. Specify a module name or use --tags to filter.`,
 						Usage:    "filter tools by tags",
 						Required: false,
 					},
+					&cli.BoolFlag{
+						Name:  keyNotes,
+						Usage: "print release notes of upgraded tools",
+						Value: false,
+					},
+					&cli.StringFlag{
+						Name:  keyNotesOut,
+						Usage: "write release notes of upgraded tools into markdown file",
+					},
+				},
+				Args: true,
+			},
+			{
+				Name:  "changelog",
+				Usage: "show release notes between the current and the latest version of tool",
+				Description: `Collect release notes of all releases between the current and the latest version of tool.
+GitHub releases are used for gh tools. For go tools the repository is detected by the module VCS origin.
+
+	$ toolset changelog golangci-lint
+	$ toolset changelog --output=CHANGELOG.md golangci-lint
+
+Set GITHUB_TOKEN (or GITLAB_TOKEN) to avoid rate limits and to read private repositories.`,
+				Action: withWorkdir(cmdChangelog),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  keyOutput,
+						Usage: "write release notes into markdown file instead of stdout",
+					},
+				},
+				Args: true,
+			},
+			{
+				Name:  "hold",
+				Usage: "protect tools from upgrades",
+				Description: `Keep tools on the current version. Held tools are skipped by 'toolset upgrade'.
+
+	$ toolset hold --reason="migrating configs" golangci-lint
+	$ toolset hold gofumpt goimports
+
+Run 'toolset unhold' to allow upgrades again.`,
+				Action: withWorkdir(cmdHold),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  keyReason,
+						Usage: "why the tool is held. It is shown on upgrade",
+					},
+				},
+				Args: true,
+			},
+			{
+				Name:  "unhold",
+				Usage: "allow upgrades of held tools",
+				Description: `Remove hold from tools.
+
+	$ toolset unhold golangci-lint`,
+				Action: withWorkdir(cmdUnhold),
+				Args:   true,
+			},
+			{
+				Name:      "convert",
+				Usage:     "convert spec to another format",
+				ArgsUsage: "json|jsonc|yaml",
+				Description: `Write spec in another format and remove the old spec file.
+
+	$ toolset convert yaml
+
+Comments are not preserved.`,
+				Action: withWorkdir(cmdConvert),
+				Args:   true,
+			},
+			{
+				Name:  "migrate",
+				Usage: "upgrade spec and lock to the current format version",
+				Description: `Upgrade spec and lock to the format of this toolset version and print what was changed.
+
+	$ toolset migrate
+	$ toolset --dry-run migrate
+
+Other commands migrate files in memory, but do not rewrite an unchanged spec.`,
+				Action: withWorkdir(cmdMigrate),
+			},
+			{
+				Name:  "validate",
+				Usage: "check spec and lock",
+				Description: `Check spec and lock without access to network: unknown fields, runtime names, module strings,
+name collisions between tools and that lock matches the spec.
+
+	$ toolset validate`,
+				Action: withWorkdir(cmdValidate),
+			},
+			{
+				Name:      "schema",
+				Usage:     "print JSON Schema of spec or lock",
+				ArgsUsage: "spec|lock",
+				Description: `Print JSON Schema of spec (default) or lock file.
+
+	$ toolset schema > toolset.schema.json
+	$ toolset schema --output=lock.schema.json lock
+
+Add "$schema" field to spec to enable completion and validation in editors.`,
+				Action: cmdSchema,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  keyOutput,
+						Usage: "write schema into file instead of stdout",
+					},
 				},
 				Args: true,
 			},
@@ -174,9 +445,11 @@ Works like upsert: adds the tool if missing, or updates if present with differen
 	$ toolset ensure go github.com/golangci/golangci-lint/cmd/golangci-lint@v1.61.0 golangci
 	$ toolset ensure go <module@version> [alias] --tags=linters
 
-This does NOT install the tool. Run 'toolset sync' afterward to install.`,
+This does NOT install the tool. Run 'toolset sync' afterward to install.
+Use --local to write the tool into untracked .toolset.local.json.`,
 				Action: withWorkdir(cmdEnsureModuleVersion),
 				Flags: []cli.Flag{
+					flagLocal,
 					&cli.StringSliceFlag{
 						Name:     keyTags,
 						Usage:    "filter tools by tags",
@@ -193,16 +466,80 @@ Shows tool metadata including aliases, tags, and last usage time.
 
 	$ toolset list
 	$ toolset list --unused
+	$ toolset list --all
 
-Use --unused flag to find tools that have never been executed (helpful for cleanup).`,
+Use --unused flag to find tools that have never been executed (helpful for cleanup).
+Use --all to list tools of the workspace root and all its members.`,
 				Flags: []cli.Flag{
+					flagAll,
 					&cli.BoolFlag{
 						Name:  keyUnused,
 						Usage: "list tools that are unused. Useful when you want to check which tools can be deleted (or excluded from tag)",
 						Value: false,
 					},
 				},
-				Action: withWorkdir(cmdList),
+				Action: withWorkdir(withWorkspace(cmdList)),
+			},
+			{
+				Name:  "outdated",
+				Usage: "list tools that have newer versions",
+				Description: `Display a table of tools that can be upgraded.
+Versions that are younger than minReleaseAge (spec) or TOOLSET_MIN_RELEASE_AGE (env) are shown as cooling down.
+
+	$ toolset outdated
+	$ toolset outdated --all`,
+				Action: withWorkdir(withWorkspace(cmdOutdated)),
+				Flags: []cli.Flag{
+					flagAll,
+				},
+			},
+			{
+				Name:  "include",
+				Usage: "manage includes",
+				Description: `Manage includes of .toolset.json. Use 'toolset add --include' to add a new one.
+
+	$ toolset include list
+	$ toolset include refresh
+	$ toolset include remove <src>`,
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "list includes",
+						Description: `Display includes with their nested includes and provided tools.
+
+	$ toolset include list`,
+						Action: withWorkdir(cmdIncludeList),
+					},
+					{
+						Name:  "remove",
+						Usage: "remove include",
+						Description: `Remove include from .toolset.json and its tools from lock.
+
+	$ toolset include remove gh:company/toolset/.toolset.json@v1.2.0`,
+						Action: withWorkdir(cmdIncludeRemove),
+						Args:   true,
+					},
+					{
+						Name:  "refresh",
+						Usage: "re-fetch includes",
+						Description: `Re-fetch one include (or all of them) and rebuild the lock. Tools of .toolset.json are not upgraded.
+
+	$ toolset include refresh
+	$ toolset include refresh gh:company/toolset/.toolset.json@v1.2.0`,
+						Action: withWorkdir(cmdIncludeRefresh),
+						Args:   true,
+					},
+				},
+			},
+			{
+				Name:  "why",
+				Usage: "explain why the tool is in the lock",
+				Description: `Trace a tool through .toolset.json, includes and their rules.
+Shows which file or URL declared the tool, the include path, inherited tags and declarations that lost a conflict.
+
+	$ toolset why golangci-lint`,
+				Action: withWorkdir(cmdWhy),
+				Args:   true,
 			},
 			{
 				Name:  "which",
@@ -238,9 +575,14 @@ Shows paths to configuration files, cache directory, and environment variables.
 
 	$ toolset info
 
+Pass a tool name to show its module, binary path, env and default args.
+
+	$ toolset info golangci-lint
+
 Useful for debugging and understanding the toolset environment.`,
-				Action: withWorkdir(cmdInfo),
-				Args:   false,
+				Action:    withWorkdir(cmdInfo),
+				Args:      true,
+				ArgsUsage: "[tool]",
 			},
 			{
 				Name:  "clear-cache",
@@ -291,7 +633,7 @@ Shows runtime names and versions available for use.
 	}
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -299,7 +641,7 @@ Shows runtime names and versions available for use.
 func cmdInit(c *cli.Context) error {
 	ctx := c.Context
 
-	fs := fsh.NewRealFS()
+	fs := newFS(c)
 
 	targetDir := c.Args().First()
 	if targetDir == "" {
@@ -330,27 +672,146 @@ func cmdInit(c *cli.Context) error {
 		fmt.Println("Copied tools:", count)
 	}
 
-	return nil
+	return printDryRun(fs, workdir.Plan{})
 }
 
 func withWorkdir(fn func(c *cli.Context, wd *workdir.Workdir) error) func(c *cli.Context) error {
 	return func(c *cli.Context) error {
-		fs := fsh.NewRealFS()
+		fs := newFS(c)
 
-		wd, err := workdir.New(c.Context, fs, "./")
+		wd, err := workdir.New(c.Context, fs, c.String(keyProject))
 		if err != nil {
 			return fmt.Errorf("new workdir: %w", err)
 		}
 
-		return fn(c, wd)
+		wd.SetDryRun(c.Bool(keyDryRun))
+		wd.SetOffline(c.Bool(keyOffline))
+
+		if err := wd.SetProfile(c.String(keyProfile)); err != nil {
+			return fmt.Errorf("set profile: %w", err)
+		}
+
+		if err := fn(c, wd); err != nil {
+			return err
+		}
+
+		return printDryRun(fs, wd.Plan())
 	}
 }
 
+// withWorkspace runs fn for the workspace root and all its members when --all flag is set. Members are opened after
+// the root, so they inherit the fresh lock of root.
+func withWorkspace(fn func(c *cli.Context, wd *workdir.Workdir) error) func(c *cli.Context, wd *workdir.Workdir) error {
+	return func(c *cli.Context, wd *workdir.Workdir) error {
+		if !c.Bool(keyAll) {
+			return fn(c, wd)
+		}
+
+		root, err := wd.WorkspaceRoot(c.Context)
+		if err != nil {
+			return fmt.Errorf("get workspace root: %w", err)
+		}
+
+		fmt.Println("Project: .")
+		if err := fn(c, root); err != nil {
+			return err
+		}
+
+		members, err := root.Members(c.Context)
+		if err != nil {
+			return fmt.Errorf("get workspace members: %w", err)
+		}
+
+		for _, member := range members {
+			name, err := filepath.Rel(root.ProjectRootDir(), member.ProjectRootDir())
+			if err != nil {
+				return fmt.Errorf("relative member path: %w", err)
+			}
+
+			fmt.Println("Project:", name)
+			if err := fn(c, member); err != nil {
+				return fmt.Errorf("project (%s): %w", name, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// newFS returns a real filesystem or an in-memory overlay on top of it in dry-run mode.
+func newFS(c *cli.Context) fsh.FS {
+	if c.Bool(keyDryRun) {
+		return fsh.NewOverlayFS(fsh.NewRealFS())
+	}
+
+	return fsh.NewRealFS()
+}
+
+// printDryRun prints a diff of spec/lock files and planned actions. Does nothing when fs is not an overlay.
+func printDryRun(fs fsh.FS, plan workdir.Plan) error {
+	overlay, ok := fs.(*fsh.OverlayFS)
+	if !ok {
+		return nil
+	}
+
+	changed, err := overlay.ChangedFiles()
+	if err != nil {
+		return fmt.Errorf("get changed files: %w", err)
+	}
+
+	fmt.Println("Dry run. Nothing was written to disk.")
+
+	for _, filename := range changed {
+		if !workdir.IsProjectFile(filename) {
+			continue
+		}
+
+		var before []byte
+		if fsh.IsExists(overlay.Base(), filename) {
+			bb, err := afero.ReadFile(overlay.Base(), filename)
+			if err != nil {
+				return fmt.Errorf("read original file: %w", err)
+			}
+
+			before = bb
+		}
+
+		after, err := afero.ReadFile(overlay, filename)
+		if err != nil {
+			return fmt.Errorf("read changed file: %w", err)
+		}
+
+		fmt.Print(diff.Unified(filename, filename, string(before), string(after)))
+	}
+
+	for _, tool := range plan.Install {
+		fmt.Println("Would install:", tool.Runtime, tool.Module)
+	}
+
+	for _, tool := range plan.Remove {
+		fmt.Println("Would remove:", tool.Runtime, tool.Module)
+	}
+
+	for _, run := range plan.Run {
+		fmt.Println("Would run:", run.Tool.Runtime, run.Tool.Module, strings.Join(run.Args, " "))
+	}
+
+	return nil
+}
+
 func cmdAdd(c *cli.Context, wd *workdir.Workdir) error {
 	ctx := c.Context
 
 	tags := c.StringSlice(keyTags)
 
+	if c.Bool(keyLocal) {
+		if c.String(keyCopyFrom) != "" || c.String(keyInclude) != "" {
+			return fmt.Errorf("local spec supports only tools, can't use --%s or --%s", keyCopyFrom, keyInclude)
+		}
+
+		wd.SetLocal(true)
+	}
+
 	if val := c.String(keyCopyFrom); val != "" {
 		count, err := wd.CopySource(ctx, val, tags)
 		if err != nil {
@@ -453,36 +914,163 @@ func cmdRun(c *cli.Context, wd *workdir.Workdir) error {
 		return fmt.Errorf("target is required")
 	}
 
+	wd.SetToolsOnPath(c.Bool(keyWithPath), getTags(c, wd))
+	wd.SetReplace(true)
+
 	if err := wd.RunTool(ctx, target, c.Args().Tail()...); err != nil {
-		if errors.Is(err, workdir.ErrToolNotFoundInSpec) {
-			fmt.Println("tool not added. Run `toolset add --help` to add this tool")
-			os.Exit(1)
-			return nil
+		return handleRunError(fmt.Errorf("run tool: %w", err))
+	}
+
+	return nil
+}
+
+func cmdExec(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("command is required")
+	}
+
+	wd.SetReplace(true)
+
+	if err := wd.Exec(ctx, getTags(c, wd), name, c.Args().Tail()...); err != nil {
+		return handleRunError(fmt.Errorf("exec: %w", err))
+	}
+
+	return nil
+}
+
+func cmdShims(c *cli.Context, wd *workdir.Workdir) error {
+	names, err := wd.WriteShims(c.Context)
+	if err != nil {
+		return fmt.Errorf("write shims: %w", err)
+	}
+
+	fmt.Println("Shims dir:", wd.ShimsDir())
+	for _, name := range names {
+		fmt.Println("  " + name)
+	}
+
+	return nil
+}
+
+func cmdEnv(c *cli.Context) error {
+	shell, err := shellenv.ParseShell(c.String(keyShell))
+	if err != nil {
+		return err
+	}
+
+	// NOTE: env is called by shell hooks on each `cd`, so it only reads the project location.
+	var dirs []string
+	activation, err := workdir.GetActivation(fsh.NewRealFS(), c.String(keyProject))
+	switch {
+	case err == nil:
+		dirs = []string{activation.ShimsDir}
+		if !activation.HasShims {
+			fmt.Fprintln(os.Stderr, "toolset: project has no shims. Run `toolset shims` to put its tools on PATH")
 		}
+	case errors.Is(err, workdir.ErrSpecNotFound):
+		// NOTE: outside of project. Only dirs of the previous activation are removed.
+	default:
+		return fmt.Errorf("get activation: %w", err)
+	}
+
+	prev := filepath.SplitList(os.Getenv(shellenv.EnvDirs))
+	fmt.Print(shellenv.Export(shell, []shellenv.Var{
+		{Name: "PATH", Value: shellenv.Activate(os.Getenv("PATH"), prev, dirs)},
+		{Name: shellenv.EnvDirs, Value: strings.Join(dirs, string(os.PathListSeparator))},
+	}))
+
+	return nil
+}
+
+func cmdHook(c *cli.Context) error {
+	script, err := shellenv.Hook(shellenv.Shell(c.Args().First()))
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(script)
+
+	return nil
+}
+
+func cmdTask(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
 
-		if errors.Is(err, workdir.ErrToolNotInstalled) {
-			fmt.Println("tool not installed. Run `toolset sync --help` to install tool before run")
-			os.Exit(1)
+	name := c.Args().First()
+	if name == "" {
+		scripts := wd.ListScripts()
+		if len(scripts) == 0 {
+			fmt.Println("No scripts. Add them into `scripts` section of spec")
 			return nil
 		}
 
-		var errRun structs.RunError
-		if errors.As(err, &errRun) {
-			os.Exit(errRun.ExitCode)
-			return nil
+		t := table.NewWriter()
+		t.AppendHeader(table.Row{"Name", "Steps", "Description"})
+		for _, script := range scripts {
+			t.AppendRow(table.Row{script.Name, len(script.Script.Steps), script.Description})
 		}
 
-		return fmt.Errorf("run tool: %w", err)
+		fmt.Println(t.Render())
+
+		return nil
+	}
+
+	if err := wd.RunScript(ctx, name); err != nil {
+		return handleRunError(fmt.Errorf("run task: %w", err))
 	}
 
 	return nil
 }
 
+// handleRunError exits with the exit code of failed tool or prints a hint for known errors.
+func handleRunError(err error) error {
+	if errors.Is(err, workdir.ErrToolNotFoundInSpec) {
+		fmt.Println("tool not added. Run `toolset add --help` to add this tool")
+		os.Exit(1)
+		return nil
+	}
+
+	if errors.Is(err, workdir.ErrToolNotInstalled) {
+		fmt.Println("tool not installed. Run `toolset sync --help` to install tool before run")
+		os.Exit(1)
+		return nil
+	}
+
+	var errRun structs.RunError
+	if errors.As(err, &errRun) {
+		os.Exit(errRun.ExitCode)
+		return nil
+	}
+
+	return err
+}
+
+// getParallel returns a number of parallel workers from flag or from selected profile.
+func getParallel(c *cli.Context, wd *workdir.Workdir) int {
+	if _, profile := wd.Profile(); !c.IsSet(keyParallel) && profile.Parallel > 0 {
+		return profile.Parallel
+	}
+
+	return c.Int(keyParallel)
+}
+
+// getTags returns tags from flag or from selected profile.
+func getTags(c *cli.Context, wd *workdir.Workdir) []string {
+	if _, profile := wd.Profile(); !c.IsSet(keyTags) {
+		return profile.Tags
+	}
+
+	return c.StringSlice(keyTags)
+}
+
 func cmdSync(c *cli.Context, wd *workdir.Workdir) error {
 	ctx := c.Context
 
-	maxWorkers := c.Int(keyParallel)
-	tags := c.StringSlice(keyTags)
+	maxWorkers := getParallel(c, wd)
+	tags := getTags(c, wd)
 
 	if err := wd.Sync(ctx, maxWorkers, tags); err != nil {
 		return fmt.Errorf("sync: %w", err)
@@ -498,7 +1086,7 @@ func cmdSync(c *cli.Context, wd *workdir.Workdir) error {
 func cmdUpgrade(c *cli.Context, wd *workdir.Workdir) error {
 	ctx := c.Context
 
-	maxWorkers := c.Int(keyParallel)
+	maxWorkers := getParallel(c, wd)
 	tags := c.StringSlice(keyTags)
 	module := c.Args().First()
 
@@ -506,6 +1094,10 @@ func cmdUpgrade(c *cli.Context, wd *workdir.Workdir) error {
 		return fmt.Errorf("can't use both module and tags")
 	}
 
+	if module == "" {
+		tags = getTags(c, wd)
+	}
+
 	filter := func(tool structs.Tool) bool { return true }
 	if module != "" {
 		fmt.Println("upgrade module:", module)
@@ -537,12 +1129,29 @@ func cmdUpgrade(c *cli.Context, wd *workdir.Workdir) error {
 		}
 	}
 
-	if err := wd.Upgrade(ctx, filter); err != nil {
+	upgraded, err := wd.Upgrade(ctx, filter)
+	if err != nil {
 		return fmt.Errorf("upgrade: %w", err)
 	}
 
-	if err := wd.Save(ctx); err != nil {
-		return fmt.Errorf("save context: %w", err)
+	if c.Bool(keyNotes) || c.String(keyNotesOut) != "" {
+		var sb strings.Builder
+		for _, u := range upgraded {
+			notes, err := wd.GetReleaseNotes(ctx, u)
+			if err != nil {
+				fmt.Println("Warning: unable to get release notes:", err)
+			}
+
+			sb.WriteString(changelog.Markdown(u.Tool.ModuleName()+": "+versionOf(u.From)+" -> "+versionOf(u.Tool.Module), notes))
+		}
+
+		if err := writeNotes(c, c.String(keyNotesOut), sb.String()); err != nil {
+			return err
+		}
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save context: %w", err)
 	}
 
 	if err := wd.Sync(ctx, maxWorkers, tags); err != nil {
@@ -556,6 +1165,57 @@ func cmdUpgrade(c *cli.Context, wd *workdir.Workdir) error {
 	return nil
 }
 
+func cmdChangelog(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	target := c.Args().First()
+	if target == "" {
+		return fmt.Errorf("target is required")
+	}
+
+	u, notes, err := wd.GetChangelog(ctx, target)
+	if err != nil {
+		return fmt.Errorf("get changelog: %w", err)
+	}
+
+	if u.From == u.Tool.Module {
+		fmt.Println("Tool is up to date:", u.Tool.Module)
+		return nil
+	}
+
+	md := changelog.Markdown(u.Tool.ModuleName()+": "+versionOf(u.From)+" -> "+versionOf(u.Tool.Module), notes)
+
+	return writeNotes(c, c.String(keyOutput), md)
+}
+
+// writeNotes prints release notes or writes them into file when filename is set.
+func writeNotes(c *cli.Context, filename, md string) error {
+	if filename == "" {
+		fmt.Print(md)
+		return nil
+	}
+
+	if c.Bool(keyDryRun) {
+		fmt.Println("Would write release notes to:", filename)
+		return nil
+	}
+
+	if err := afero.WriteFile(newFS(c), filename, []byte(md), 0o644); err != nil {
+		return fmt.Errorf("write release notes: %w", err)
+	}
+
+	fmt.Println("Release notes written to:", filename)
+
+	return nil
+}
+
+// versionOf returns a version part of module.
+func versionOf(module string) string {
+	_, ver, _ := strings.Cut(module, "@")
+
+	return ver
+}
+
 func cmdList(c *cli.Context, wd *workdir.Workdir) error {
 	ctx := c.Context
 
@@ -590,15 +1250,21 @@ func cmdList(c *cli.Context, wd *workdir.Workdir) error {
 			lastUse = timeh.Duration(time.Since(val))
 		}
 
+		var installed any = ts.Module.IsInstalled
+		if !ts.Tool.IsSupported() {
+			installed = "unsupported"
+		}
+
 		rows = append(rows, table.Row{
 			ts.Tool.Runtime,
 			ts.Module.Name,
 			ts.Module.Mod.Version(),
-			ts.Module.IsInstalled,
+			installed,
 			lastUse,
 			ts.Module.IsPrivate,
 			ts.Tool.Alias.ValDefault("---"),
 			strings.Join(ts.Tool.Tags, ","),
+			holdStatus(ts.Tool),
 			ts.Tool.Module,
 		})
 	}
@@ -613,7 +1279,90 @@ func cmdList(c *cli.Context, wd *workdir.Workdir) error {
 		"Private",
 		"Alias",
 		"Tags",
+		"Hold",
+		"Module",
+	})
+
+	t.AppendRows(rows)
+
+	res := t.Render()
+	fmt.Println(res)
+
+	return nil
+}
+
+func cmdOutdated(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	tools, err := wd.GetOutdated(ctx)
+	if err != nil {
+		return fmt.Errorf("get outdated tools: %w", err)
+	}
+
+	sort.SliceStable(tools, func(i, j int) bool {
+		return tools[i].Tool.ModuleName() < tools[j].Tool.ModuleName()
+	})
+
+	rows := make([]table.Row, 0, len(tools))
+	for _, o := range tools {
+		status := "outdated"
+		switch {
+		case o.Tool.Hold:
+			status = holdStatus(o.Tool)
+		case o.IsCoolingDown():
+			status = "cooling down"
+		}
+
+		rows = append(rows, table.Row{
+			o.Tool.Runtime,
+			o.Tool.Module,
+			o.Latest,
+			o.Allowed,
+			status,
+		})
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{
+		"Runtime",
 		"Module",
+		"Latest",
+		"Upgrade To",
+		"Status",
+	})
+
+	t.AppendRows(rows)
+
+	res := t.Render()
+	fmt.Println(res)
+
+	return nil
+}
+
+func cmdIncludeList(_ *cli.Context, wd *workdir.Workdir) error {
+	rows := make([]table.Row, 0)
+	for _, info := range wd.ListIncludes() {
+		nested := make([]string, 0, len(info.Remotes))
+		for _, remote := range info.Remotes {
+			if remote.Source != info.Include.Src {
+				nested = append(nested, remote.Source)
+			}
+		}
+
+		rows = append(rows, table.Row{
+			info.Include.Src,
+			strings.Join(info.Include.Tags, ","),
+			len(info.Tools),
+			strings.Join(nested, "\n"),
+		})
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{
+		"Source",
+		"Tags",
+		"Tools",
+		"Nested Includes",
 	})
 
 	t.AppendRows(rows)
@@ -624,6 +1373,139 @@ func cmdList(c *cli.Context, wd *workdir.Workdir) error {
 	return nil
 }
 
+func cmdIncludeRemove(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	targets := c.Args().Slice()
+	if len(targets) == 0 {
+		return fmt.Errorf("target is required")
+	}
+
+	for _, target := range targets {
+		diff, err := wd.RemoveInclude(target)
+		if err != nil {
+			return fmt.Errorf("remove include: %w", err)
+		}
+
+		printToolsDiff(target, diff)
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	return nil
+}
+
+func cmdIncludeRefresh(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	targets := c.Args().Slice()
+	if len(targets) == 0 {
+		for _, info := range wd.ListIncludes() {
+			targets = append(targets, info.Include.Src)
+		}
+	}
+
+	for _, target := range targets {
+		diff, err := wd.RefreshInclude(ctx, target)
+		if err != nil {
+			return fmt.Errorf("refresh include: %w", err)
+		}
+
+		printToolsDiff(target, diff)
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	return nil
+}
+
+func printToolsDiff(src string, diff *workdir.ToolsDiff) {
+	if diff.IsEmpty() {
+		fmt.Println(src + ": no changes")
+		return
+	}
+
+	fmt.Println(src + ":")
+	for _, tool := range diff.Added {
+		fmt.Println("  + " + tool.Module)
+	}
+
+	for _, tool := range diff.Removed {
+		fmt.Println("  - " + tool.Module)
+	}
+
+	for _, change := range diff.Changed {
+		if change.From.Module == change.To.Module {
+			fmt.Println("  ~ " + change.To.Module + " (source: " + change.From.Source + " -> " + change.To.Source + ")")
+			continue
+		}
+
+		fmt.Println("  ~ " + change.From.Module + " -> " + change.To.Module)
+	}
+}
+
+func cmdWhy(c *cli.Context, wd *workdir.Workdir) error {
+	target := c.Args().First()
+	if target == "" {
+		return fmt.Errorf("target is required")
+	}
+
+	why, err := wd.Why(target)
+	if err != nil {
+		return fmt.Errorf("why: %w", err)
+	}
+
+	if why.IsLocked {
+		fmt.Printf("%s is locked as %s:%s\n", target, why.Locked.Runtime, why.Locked.Module)
+	} else {
+		fmt.Printf("%s is not locked\n", target)
+	}
+
+	for i, decl := range why.Declarations {
+		fmt.Printf("\n%d. [%s] %s\n", i+1, decl.Status, decl.Tool.Module)
+
+		if decl.Source == "" {
+			fmt.Println("   declared in: project spec")
+		} else {
+			fmt.Println("   declared in:", decl.Source)
+
+			path := []string{"project spec"}
+			for _, inc := range decl.Includes {
+				path = append(path, inc.Src)
+			}
+			fmt.Println("   include path:", strings.Join(path, " -> "))
+		}
+
+		switch {
+		case decl.Status == workdir.DeclarationExcluded:
+			fmt.Println("   excluded by rules of include:", decl.RuleSource)
+		case decl.IsOverridden():
+			fmt.Println("   overridden by rules of include:", decl.RuleSource, "=>", decl.Result.Module)
+		}
+
+		tags := make([]string, 0, len(decl.Tags))
+		for _, tag := range decl.Tags {
+			switch {
+			case tag.Source == "":
+				tags = append(tags, tag.Tag+" (own)")
+			case tag.IsRule:
+				tags = append(tags, tag.Tag+" (set by rules of include "+tag.Source+")")
+			default:
+				tags = append(tags, tag.Tag+" (from include "+tag.Source+")")
+			}
+		}
+		if len(tags) != 0 {
+			fmt.Println("   tags:", strings.Join(tags, ", "))
+		}
+	}
+
+	return nil
+}
+
 func cmdWhich(c *cli.Context, wd *workdir.Workdir) error {
 	targets := c.Args().Slice()
 	if len(targets) == 0 {
@@ -675,7 +1557,159 @@ func cmdRemove(c *cli.Context, wd *workdir.Workdir) error {
 	return nil
 }
 
-func cmdInfo(_ *cli.Context, wd *workdir.Workdir) error {
+func cmdHold(c *cli.Context, wd *workdir.Workdir) error {
+	return setHold(c, wd, true)
+}
+
+func cmdUnhold(c *cli.Context, wd *workdir.Workdir) error {
+	return setHold(c, wd, false)
+}
+
+func setHold(c *cli.Context, wd *workdir.Workdir, hold bool) error {
+	ctx := c.Context
+
+	targets := c.Args().Slice()
+	if len(targets) == 0 {
+		return fmt.Errorf("target is required")
+	}
+
+	for _, target := range targets {
+		if err := wd.SetHold(target, hold, c.String(keyReason)); err != nil {
+			return fmt.Errorf("set hold (%s): %w", target, err)
+		}
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	return nil
+}
+
+func cmdConvert(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	if c.NArg() != 1 {
+		return fmt.Errorf("format is required")
+	}
+
+	format, err := specfile.ParseFormat(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	filename, err := wd.Convert(ctx, format)
+	if err != nil {
+		return fmt.Errorf("convert: %w", err)
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	fmt.Println("Spec converted:", filename)
+
+	return nil
+}
+
+func cmdMigrate(c *cli.Context, wd *workdir.Workdir) error {
+	ctx := c.Context
+
+	migrated := wd.Migrate()
+	if migrated.IsEmpty() {
+		fmt.Println("Spec and lock are up to date")
+		return nil
+	}
+
+	for _, part := range []struct {
+		name    string
+		changes []migrations.Change
+	}{
+		{"Spec", migrated.Spec},
+		{"Lock", migrated.Lock},
+	} {
+		if len(part.changes) == 0 {
+			continue
+		}
+
+		fmt.Println(part.name + ":")
+		for _, change := range part.changes {
+			fmt.Println("  " + change.String())
+		}
+	}
+
+	if err := wd.Save(ctx); err != nil {
+		return fmt.Errorf("save: %w", err)
+	}
+
+	return nil
+}
+
+func cmdValidate(c *cli.Context, wd *workdir.Workdir) error {
+	problems := wd.Validate()
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("found %d problem(s)", len(problems))
+	}
+
+	fmt.Println("Spec and lock are valid")
+
+	return nil
+}
+
+func cmdSchema(c *cli.Context) error {
+	var schema *jsonschema.Schema
+	switch target := c.Args().First(); target {
+	case "", "spec":
+		schema = structs.SpecSchema()
+	case "lock":
+		schema = structs.LockSchema()
+	default:
+		return fmt.Errorf("unknown schema (%s): expected spec or lock", target)
+	}
+
+	bb, err := json.MarshalIndent(schema, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshal schema: %w", err)
+	}
+
+	bb = append(bb, '\n')
+
+	filename := c.String(keyOutput)
+	if filename == "" {
+		fmt.Print(string(bb))
+		return nil
+	}
+
+	fs := newFS(c)
+	if err := afero.WriteFile(fs, filename, bb, 0o644); err != nil {
+		return fmt.Errorf("write schema: %w", err)
+	}
+
+	return printDryRun(fs, workdir.Plan{})
+}
+
+// holdStatus returns a human-readable hold status of tool.
+func holdStatus(tool structs.Tool) string {
+	if !tool.Hold {
+		return "---"
+	}
+
+	if tool.HoldReason == "" {
+		return "held"
+	}
+
+	return "held: " + tool.HoldReason
+}
+
+func cmdInfo(c *cli.Context, wd *workdir.Workdir) error {
+	if target := c.Args().First(); target != "" {
+		return cmdInfoTool(wd, target)
+	}
+
 	info, err := wd.GetSystemInfo()
 	if err != nil {
 		return fmt.Errorf("get system info: %w", err)
@@ -693,11 +1727,17 @@ func cmdInfo(_ *cli.Context, wd *workdir.Workdir) error {
 		{"Cache dir:", info.Locations.CacheDir},
 		{"Toolset File:", info.Locations.ToolsetFile},
 		{"Toolset Lock File:", info.Locations.ToolsetLockFile},
+		{"Local Toolset File:", info.Locations.LocalFile},
+		{"Shims Dir:", info.Locations.ShimsDir},
 		{"Project Root Dir:", info.Locations.ProjectRootDir},
 		{"Current Dir:", info.Locations.CurrentDir},
 		{"Stats File:", info.Locations.StatsFile},
 	}
 
+	if name, _ := wd.Profile(); name != "" {
+		rows = append(rows, table.Row{"Profile:", name})
+	}
+
 	for _, env := range info.Envs {
 		rows = append(rows, table.Row{"ENV:" + env[0], env[1]})
 	}
@@ -710,15 +1750,59 @@ func cmdInfo(_ *cli.Context, wd *workdir.Workdir) error {
 	return nil
 }
 
-func cmdClearCache(_ *cli.Context, wd *workdir.Workdir) error {
+func cmdInfoTool(wd *workdir.Workdir, target string) error {
+	ts, err := wd.FindTool(target)
+	if err != nil {
+		return fmt.Errorf("find tool: %w", err)
+	}
+
+	// NOTE: placeholder shows where user's args are placed.
+	opts := wd.RunOpts(ts.Tool, []string{"<args>"})
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{
+		"Property",
+		"Value",
+	})
+
+	rows := []table.Row{
+		{"Runtime:", ts.Tool.Runtime},
+		{"Module:", ts.Tool.Module},
+		{"Alias:", ts.Tool.Alias.ValDefault("")},
+		{"Installed:", ts.Module.IsInstalled},
+		{"Binary:", ts.Module.BinPath},
+		{"Source:", ts.Tool.Source},
+		{"Platforms:", strings.Join(ts.Tool.Platforms, ", ")},
+		{"Args:", strings.Join(opts.Args, " ")},
+	}
+
+	for _, env := range opts.Env {
+		key, val, _ := strings.Cut(env, "=")
+		rows = append(rows, table.Row{"ENV:" + key, val})
+	}
+
+	t.AppendRows(rows)
+
+	res := t.Render()
+	fmt.Println(res)
+
+	return nil
+}
+
+func cmdClearCache(c *cli.Context, wd *workdir.Workdir) error {
 	info, err := wd.GetSystemInfo()
 	if err != nil {
 		return fmt.Errorf("get system info: %w", err)
 	}
 
+	if c.Bool(keyDryRun) {
+		fmt.Println("Would remove cache dir:", info.Locations.CacheDir)
+		return nil
+	}
+
 	fmt.Println("Removing cache dir:", info.Locations.CacheDir)
 
-	if err := os.RemoveAll(info.Locations.CacheDir); err != nil {
+	if err := wd.ClearCache(); err != nil {
 		return fmt.Errorf("remove cache dir: %w", err)
 	}
 
@@ -744,6 +1828,8 @@ func cmdEnsureModuleVersion(c *cli.Context, wd *workdir.Workdir) error {
 		alias.Set(aliasStr)
 	}
 
+	wd.SetLocal(c.Bool(keyLocal))
+
 	mod, err := wd.Ensure(ctx, runtime, module, alias, tags)
 	if err != nil {
 		return fmt.Errorf("ensure module: %w", err)
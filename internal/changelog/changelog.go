@@ -0,0 +1,230 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/kazhuravlev/toolset/internal/ghclient"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	EnvGitlabToken = "GITLAB_TOKEN"
+
+	maxPages = 10
+)
+
+var ErrUnsupportedHost = errors.New("unsupported repository host")
+
+// FromRepo collects release notes of repository between two versions (from, to]. Repository URL should look like
+// https://github.com/owner/repo or https://gitlab.com/group/project. Tag prefix is used for modules that are placed
+// in a subdirectory of repository, like `tools/`.
+func FromRepo(ctx context.Context, repoURL, tagPrefix, from, to string) ([]structs.ReleaseNote, error) {
+	u, err := url.Parse(strings.TrimSuffix(repoURL, ".git"))
+	if err != nil {
+		return nil, fmt.Errorf("parse repo url: %w", err)
+	}
+
+	project := strings.Trim(u.Path, "/")
+
+	switch {
+	default:
+		return nil, fmt.Errorf("%s: %w", u.Host, ErrUnsupportedHost)
+	case u.Host == "github.com":
+		owner, repo, ok := strings.Cut(project, "/")
+		if !ok {
+			return nil, fmt.Errorf("unexpected github repository (%s)", repoURL)
+		}
+
+		return FromGithub(ctx, ghclient.New(ctx), owner, repo, tagPrefix, from, to)
+	case strings.Contains(u.Host, "gitlab"):
+		return FromGitlab(ctx, http.DefaultClient, u.Scheme+"://"+u.Host, project, tagPrefix, from, to)
+	}
+}
+
+// FromGithub collects notes from GitHub releases. Releases are listed by date, so a backport release can be placed
+// between releases of the range. That's why all pages are read (up to maxPages), not only pages until `from`.
+func FromGithub(ctx context.Context, client *github.Client, owner, repo, tagPrefix, from, to string) ([]structs.ReleaseNote, error) {
+	var res []structs.ReleaseNote
+
+	opts := &github.ListOptions{PerPage: 100}
+	for range maxPages {
+		releases, resp, err := client.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("list releases: %w", err)
+		}
+
+		for _, release := range releases {
+			if release.GetDraft() {
+				continue
+			}
+
+			note := structs.ReleaseNote{
+				Version:     release.GetTagName(),
+				Name:        release.GetName(),
+				URL:         release.GetHTMLURL(),
+				Body:        release.GetBody(),
+				PublishedAt: release.GetPublishedAt().Time,
+			}
+
+			if inRange(note.Version, tagPrefix, from, to) == rangeIn {
+				res = append(res, note)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return sortNotes(res, tagPrefix), nil
+}
+
+type gitlabRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ReleasedAt  time.Time `json:"released_at"`
+	Links       struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+}
+
+// FromGitlab collects notes from GitLab releases. GITLAB_TOKEN env is used for private projects. All pages are read
+// (up to maxPages) for the same reason as in FromGithub.
+func FromGitlab(ctx context.Context, client *http.Client, baseURL, project, tagPrefix, from, to string) ([]structs.ReleaseNote, error) {
+	var res []structs.ReleaseNote
+
+	for page := 1; page <= maxPages; page++ {
+		reqURL := fmt.Sprintf("%s/api/v4/projects/%s/releases?per_page=100&page=%d", baseURL, url.PathEscape(project), page)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+
+		if token := os.Getenv(EnvGitlabToken); token != "" {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}
+
+		releases, err := doGitlab(client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, release := range releases {
+			note := structs.ReleaseNote{
+				Version:     release.TagName,
+				Name:        release.Name,
+				URL:         release.Links.Self,
+				Body:        release.Description,
+				PublishedAt: release.ReleasedAt,
+			}
+
+			if inRange(note.Version, tagPrefix, from, to) == rangeIn {
+				res = append(res, note)
+			}
+		}
+
+		if len(releases) < 100 {
+			break
+		}
+	}
+
+	return sortNotes(res, tagPrefix), nil
+}
+
+func doGitlab(client *http.Client, req *http.Request) ([]gitlabRelease, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("list releases: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list releases: unexpected status: %s", resp.Status)
+	}
+
+	var releases []gitlabRelease
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("decode releases: %w", err)
+	}
+
+	return releases, nil
+}
+
+type rangePos int
+
+const (
+	rangeSkip rangePos = iota
+	rangeBefore
+	rangeIn
+	rangeAfter
+)
+
+// inRange checks that tag is in range (from, to]. Tags with another prefix or non-semver tags are skipped.
+func inRange(tag, tagPrefix, from, to string) rangePos {
+	ver, ok := strings.CutPrefix(tag, tagPrefix)
+	if !ok || !semver.IsValid(ver) {
+		return rangeSkip
+	}
+
+	switch {
+	case semver.Compare(ver, from) <= 0:
+		return rangeBefore
+	case semver.Compare(ver, to) > 0:
+		return rangeAfter
+	default:
+		return rangeIn
+	}
+}
+
+// sortNotes sorts notes from the newest to the oldest version.
+func sortNotes(notes []structs.ReleaseNote, tagPrefix string) []structs.ReleaseNote {
+	slices.SortStableFunc(notes, func(a, b structs.ReleaseNote) int {
+		return semver.Compare(strings.TrimPrefix(b.Version, tagPrefix), strings.TrimPrefix(a.Version, tagPrefix))
+	})
+
+	return notes
+}
+
+// Markdown renders release notes of one tool. Result can be pasted into upgrade PR.
+func Markdown(title string, notes []structs.ReleaseNote) string {
+	var sb strings.Builder
+	sb.WriteString("## " + title + "\n\n")
+
+	if len(notes) == 0 {
+		sb.WriteString("No release notes found.\n\n")
+		return sb.String()
+	}
+
+	for _, note := range notes {
+		header := note.Version
+		if note.URL != "" {
+			header = fmt.Sprintf("[%s](%s)", note.Version, note.URL)
+		}
+
+		if !note.PublishedAt.IsZero() {
+			header += " (" + note.PublishedAt.Format(time.DateOnly) + ")"
+		}
+
+		sb.WriteString("### " + header + "\n\n")
+
+		if body := strings.TrimSpace(note.Body); body != "" {
+			sb.WriteString(body + "\n\n")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,97 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInRange(t *testing.T) {
+	f := func(tag, prefix string, exp rangePos) {
+		require.Equal(t, exp, inRange(tag, prefix, "v1.1.0", "v1.3.0"), tag)
+	}
+
+	f("v1.0.0", "", rangeBefore)
+	f("v1.1.0", "", rangeBefore)
+	f("v1.2.0", "", rangeIn)
+	f("v1.3.0", "", rangeIn)
+	f("v1.4.0", "", rangeAfter)
+	f("nightly", "", rangeSkip)
+	f("tools/v1.2.0", "", rangeSkip)
+	f("tools/v1.2.0", "tools/", rangeIn)
+	f("v1.2.0", "tools/", rangeSkip)
+}
+
+func TestFromGitlab(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v4/projects/group%2Fproject/releases", r.URL.EscapedPath())
+
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"tag_name": "v1.4.0", "description": "four", "released_at": "2025-04-01T00:00:00Z"},
+			{"tag_name": "v1.3.0", "description": "three", "released_at": "2025-03-01T00:00:00Z"},
+			{"tag_name": "v1.2.0", "description": "two", "released_at": "2025-02-01T00:00:00Z"},
+			{"tag_name": "v1.1.0", "description": "one", "released_at": "2025-01-01T00:00:00Z"},
+		})
+	}))
+	defer srv.Close()
+
+	notes, err := FromGitlab(context.Background(), srv.Client(), srv.URL, "group/project", "", "v1.1.0", "v1.3.0")
+	require.NoError(t, err)
+	require.Equal(t, []structs.ReleaseNote{
+		{Version: "v1.3.0", Body: "three", PublishedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Version: "v1.2.0", Body: "two", PublishedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}, notes)
+}
+
+func TestFromGithub(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/owner/repo/releases", r.URL.Path)
+
+		// NOTE: backport v1.0.5 was published after v1.2.0, so the range continues on the next page.
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/owner/repo/releases?page=2>; rel="next"`, srv.URL))
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"tag_name": "v1.3.0", "body": "three", "published_at": "2025-04-01T00:00:00Z"},
+				{"tag_name": "v1.0.5", "body": "backport", "published_at": "2025-03-01T00:00:00Z"},
+			})
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"tag_name": "v1.2.0", "body": "two", "published_at": "2025-02-01T00:00:00Z"},
+			{"tag_name": "v1.1.0", "body": "one", "published_at": "2025-01-01T00:00:00Z"},
+		})
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	notes, err := FromGithub(context.Background(), client, "owner", "repo", "", "v1.1.0", "v1.3.0")
+	require.NoError(t, err)
+	require.Equal(t, []structs.ReleaseNote{
+		{Version: "v1.3.0", Body: "three", PublishedAt: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{Version: "v1.2.0", Body: "two", PublishedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}, notes)
+}
+
+func TestMarkdown(t *testing.T) {
+	require.Equal(t, "## tool: v1 -> v2\n\nNo release notes found.\n\n", Markdown("tool: v1 -> v2", nil))
+
+	md := Markdown("tool", []structs.ReleaseNote{
+		{Version: "v1.3.0", URL: "https://example.com/v1.3.0", Body: " three\n", PublishedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Version: "v1.2.0"},
+	})
+	require.Equal(t, "## tool\n\n### [v1.3.0](https://example.com/v1.3.0) (2025-03-01)\n\nthree\n\n### v1.2.0\n\n", md)
+}
@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const contextLines = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+	// Line numbers (0-based) in both texts at the moment of this operation.
+	aIdx, bIdx int
+}
+
+// Unified returns a unified diff between two texts. Returns an empty string when texts are equal.
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	ops := lineOps(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + fromName + "\n")
+	sb.WriteString("+++ " + toName + "\n")
+
+	for _, h := range hunks(ops) {
+		writeHunk(&sb, ops[h[0]:h[1]])
+	}
+
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// lineOps builds a list of operations which transform a into b. It uses a classic LCS table because
+// spec and lock files are small.
+func lineOps(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	res := make([]op, 0, len(a)+len(b))
+
+	var i, j int
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			res = append(res, op{kind: opEqual, line: a[i], aIdx: i, bIdx: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			res = append(res, op{kind: opDelete, line: a[i], aIdx: i, bIdx: j})
+			i++
+		default:
+			res = append(res, op{kind: opInsert, line: b[j], aIdx: i, bIdx: j})
+			j++
+		}
+	}
+
+	return res
+}
+
+// hunks returns [start, end) ranges of ops which should be printed.
+func hunks(ops []op) [][2]int {
+	var res [][2]int
+
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+
+		start := max(i-contextLines, 0)
+		end := min(i+1+contextLines, len(ops))
+
+		if len(res) > 0 && start <= res[len(res)-1][1] {
+			res[len(res)-1][1] = end
+		} else {
+			res = append(res, [2]int{start, end})
+		}
+	}
+
+	return res
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	var aLen, bLen int
+	for _, o := range ops {
+		if o.kind != opInsert {
+			aLen++
+		}
+
+		if o.kind != opDelete {
+			bLen++
+		}
+	}
+
+	aStart, bStart := ops[0].aIdx+1, ops[0].bIdx+1
+	if aLen == 0 {
+		aStart--
+	}
+
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+
+	for _, o := range ops {
+		sb.WriteByte(byte(o.kind))
+		sb.WriteString(o.line)
+
+		if !strings.HasSuffix(o.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
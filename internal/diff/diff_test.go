@@ -0,0 +1,62 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/diff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	f := func(name, from, to, exp string) {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, exp, diff.Unified("a", "b", from, to))
+		})
+	}
+
+	f("equal", "a\nb\n", "a\nb\n", "")
+	f("from_empty", "", "a\nb\n", `--- a
++++ b
+@@ -0,0 +1,2 @@
++a
++b
+`)
+	f("to_empty", "a\n", "", `--- a
++++ b
+@@ -1,1 +0,0 @@
+-a
+`)
+	f("change_in_the_middle", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\n2\n3\n4\nX\n6\n7\n8\n9\n", `--- a
++++ b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++X
+ 6
+ 7
+ 8
+`)
+	f("two_hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n", `--- a
++++ b
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -7,4 +8,3 @@
+ 7
+ 8
+ 9
+-10
+`)
+	f("no_newline_at_end", "a", "b", `--- a
++++ b
+@@ -1,1 +1,1 @@
+-a
+\ No newline at end of file
++b
+\ No newline at end of file
+`)
+}
@@ -0,0 +1,175 @@
+package fsh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var _ FS = (*OverlayFS)(nil)
+
+// OverlayFS is a copy-on-write filesystem. It reads files from the base filesystem, but all changes are stored
+// in memory. The only exception is temp directories (see afero.TempDir) - external programs (go, git) work with
+// them directly, so they are created in the base filesystem.
+type OverlayFS struct {
+	afero.Fs
+	base    FS
+	layer   afero.Fs
+	tempDir string
+	lockMu  *sync.Mutex
+
+	tempMu   *sync.Mutex
+	tempDirs []string
+}
+
+func NewOverlayFS(base FS) *OverlayFS {
+	layer := afero.NewMemMapFs()
+
+	return &OverlayFS{
+		Fs:      afero.NewCopyOnWriteFs(base, layer),
+		base:    base,
+		layer:   layer,
+		tempDir: filepath.Clean(os.TempDir()),
+		lockMu:  new(sync.Mutex),
+		tempMu:  new(sync.Mutex),
+	}
+}
+
+// Base returns the underlying filesystem.
+func (o *OverlayFS) Base() FS {
+	return o.base
+}
+
+// ChangedFiles returns a sorted list of files that were created or modified in overlay.
+func (o *OverlayFS) ChangedFiles() ([]string, error) {
+	res := make([]string, 0)
+	err := afero.Walk(o.layer, string(filepath.Separator), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			res = append(res, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk overlay: %w", err)
+	}
+
+	return res, nil
+}
+
+func (o *OverlayFS) isTemp(path string) bool {
+	path = filepath.Clean(path)
+
+	o.tempMu.Lock()
+	defer o.tempMu.Unlock()
+
+	for _, dir := range o.tempDirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *OverlayFS) fsFor(path string) afero.Fs {
+	if o.isTemp(path) {
+		return o.base
+	}
+
+	return o.Fs
+}
+
+func (o *OverlayFS) Create(name string) (afero.File, error) {
+	return o.fsFor(name).Create(name)
+}
+
+func (o *OverlayFS) Mkdir(name string, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	if filepath.Dir(name) != o.tempDir {
+		return o.fsFor(name).Mkdir(name, perm)
+	}
+
+	// This is a new temp directory.
+	if err := o.base.Mkdir(name, perm); err != nil {
+		return err
+	}
+
+	o.tempMu.Lock()
+	o.tempDirs = append(o.tempDirs, name)
+	o.tempMu.Unlock()
+
+	return nil
+}
+
+func (o *OverlayFS) MkdirAll(path string, perm os.FileMode) error {
+	return o.fsFor(path).MkdirAll(path, perm)
+}
+
+func (o *OverlayFS) Open(name string) (afero.File, error) {
+	return o.fsFor(name).Open(name)
+}
+
+func (o *OverlayFS) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return o.fsFor(name).OpenFile(name, flag, perm)
+}
+
+func (o *OverlayFS) Remove(name string) error {
+	return o.fsFor(name).Remove(name)
+}
+
+func (o *OverlayFS) RemoveAll(path string) error {
+	return o.fsFor(path).RemoveAll(path)
+}
+
+func (o *OverlayFS) Rename(oldname, newname string) error {
+	return o.fsFor(oldname).Rename(oldname, newname)
+}
+
+func (o *OverlayFS) Stat(name string) (os.FileInfo, error) {
+	return o.fsFor(name).Stat(name)
+}
+
+func (o *OverlayFS) Chmod(name string, mode os.FileMode) error {
+	return o.fsFor(name).Chmod(name, mode)
+}
+
+func (o *OverlayFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return o.fsFor(name).Chtimes(name, atime, mtime)
+}
+
+func (o *OverlayFS) SymlinkIfPossible(oldname, newname string) error {
+	return nil
+}
+
+func (o *OverlayFS) GetCurrentDir() string {
+	return o.base.GetCurrentDir()
+}
+
+func (o *OverlayFS) GetHomeDir() (string, error) {
+	return o.base.GetHomeDir()
+}
+
+func (o *OverlayFS) Walk(root string, fn filepath.WalkFunc) error {
+	return afero.Walk(o, root, fn)
+}
+
+func (o *OverlayFS) RLock(_ context.Context, _ string) (func(), error) {
+	o.lockMu.Lock()
+	return o.lockMu.Unlock, nil
+}
+
+func (o *OverlayFS) Lock(_ context.Context, _ string) (func(), error) {
+	o.lockMu.Lock()
+	return o.lockMu.Unlock, nil
+}
@@ -0,0 +1,62 @@
+package fsh_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	t.Run("changes_are_not_written_to_base", func(t *testing.T) {
+		base := fsh.NewMemFS(map[string]string{
+			"/dir/file1.txt": `Hello 1!`,
+		})
+		fs := fsh.NewOverlayFS(base)
+
+		require.NoError(t, afero.WriteFile(fs, "/dir/file1.txt", []byte(`Changed!`), 0o644))
+		require.NoError(t, afero.WriteFile(fs, "/dir/file2.txt", []byte(`Hello 2!`), 0o644))
+
+		bb, err := afero.ReadFile(fs, "/dir/file1.txt")
+		require.NoError(t, err)
+		require.Equal(t, `Changed!`, string(bb))
+
+		bb, err = afero.ReadFile(base, "/dir/file1.txt")
+		require.NoError(t, err)
+		require.Equal(t, `Hello 1!`, string(bb))
+		require.False(t, fsh.IsExists(base, "/dir/file2.txt"))
+
+		changed, err := fs.ChangedFiles()
+		require.NoError(t, err)
+		require.Equal(t, []string{"/dir/file1.txt", "/dir/file2.txt"}, changed)
+	})
+
+	t.Run("temp_dir_is_passed_to_base", func(t *testing.T) {
+		base := fsh.NewMemFS(nil)
+		fs := fsh.NewOverlayFS(base)
+
+		tempDir, err := afero.TempDir(fs, "", "toolset-overlay")
+		require.NoError(t, err)
+
+		filename := filepath.Join(tempDir, "file.txt")
+		require.NoError(t, afero.WriteFile(fs, filename, []byte(`temp`), 0o644))
+		require.True(t, fsh.IsExists(base, filename))
+
+		otherFile := filepath.Join(os.TempDir(), "project", "file.txt")
+		require.NoError(t, fs.MkdirAll(filepath.Dir(otherFile), fsh.DefaultDirPerm))
+		require.NoError(t, afero.WriteFile(fs, otherFile, []byte(`project`), 0o644))
+		require.False(t, fsh.IsExists(base, otherFile))
+
+		changed, err := fs.ChangedFiles()
+		require.NoError(t, err)
+		require.Equal(t, []string{otherFile}, changed)
+	})
+}
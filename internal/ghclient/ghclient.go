@@ -0,0 +1,51 @@
+package ghclient
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v75/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	EnvToolsetGithubToken = "TOOLSET_GITHUB_TOKEN"
+	EnvGithubToken        = "GITHUB_TOKEN"
+)
+
+// Token returns a GitHub token from env. TOOLSET_GITHUB_TOKEN has a priority over GITHUB_TOKEN.
+func Token() string {
+	if token := os.Getenv(EnvToolsetGithubToken); token != "" {
+		return token
+	}
+
+	return os.Getenv(EnvGithubToken)
+}
+
+// NewHTTPClient returns a http client that authorizes all requests with GitHub token (when it is set).
+func NewHTTPClient(ctx context.Context) *http.Client {
+	token := Token()
+	if token == "" {
+		return &http.Client{}
+	}
+
+	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+
+	return oauth2.NewClient(ctx, src)
+}
+
+// New returns a GitHub API client.
+func New(ctx context.Context) *github.Client {
+	return github.NewClient(NewHTTPClient(ctx))
+}
+
+// IsGithubHost returns true for hosts that accept GitHub token, like api or raw content hosts.
+func IsGithubHost(host string) bool {
+	switch host {
+	case "github.com", "api.github.com", "raw.githubusercontent.com", "gist.githubusercontent.com":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,130 @@
+// Package jsonschema generates JSON Schema (draft 2020-12) documents from Go types.
+package jsonschema
+
+import (
+	"reflect"
+	"strings"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Type is a type name or a list of type names.
+	Type    any      `json:"type,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Override changes a schema that was generated for a type. It is useful for types with custom json marshaling.
+type Override func(s *Schema) *Schema
+
+// Reflect returns a schema for type of v. Named struct types are placed into $defs. Struct fields are described by
+// json tags. Use `jsonschema:"required"` tag to mark field as required.
+func Reflect(v any, overrides map[reflect.Type]Override) *Schema {
+	r := reflector{
+		overrides: overrides,
+		defs:      make(map[string]*Schema),
+	}
+
+	res := r.reflect(reflect.TypeOf(v))
+	res.Schema = Draft
+	res.Defs = r.defs
+
+	return res
+}
+
+type reflector struct {
+	overrides map[reflect.Type]Override
+	defs      map[string]*Schema
+}
+
+func (r *reflector) reflect(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if override, ok := r.overrides[t]; ok {
+		return override(r.reflectType(t))
+	}
+
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return r.reflectType(t)
+	}
+
+	name := t.Name()
+	if _, ok := r.defs[name]; !ok {
+		// Reserve the name to support recursive types.
+		r.defs[name] = new(Schema)
+		*r.defs[name] = *r.reflectType(t)
+	}
+
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+func (r *reflector) reflectType(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		// NOTE: nil slices and maps are encoded as null.
+		return &Schema{Type: []string{"array", "null"}, Items: r.reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Struct:
+		return r.reflectStruct(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (r *reflector) reflectStruct(t reflect.Type) *Schema {
+	res := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+
+		res.Properties[name] = r.reflect(field.Type)
+
+		if field.Tag.Get("jsonschema") == "required" {
+			res.Required = append(res.Required, name)
+		}
+	}
+
+	return res
+}
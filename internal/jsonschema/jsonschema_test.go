@@ -0,0 +1,47 @@
+package jsonschema_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/jsonschema"
+	"github.com/stretchr/testify/require"
+)
+
+type Level string
+
+type Node struct {
+	Name     string            `json:"name" jsonschema:"required"`
+	Level    Level             `json:"level,omitempty"`
+	Children []Node            `json:"children"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Ignored  string            `json:"-"`
+	private  string
+}
+
+func TestReflect(t *testing.T) {
+	res := jsonschema.Reflect(Node{}, map[reflect.Type]jsonschema.Override{
+		reflect.TypeFor[Level](): func(s *jsonschema.Schema) *jsonschema.Schema {
+			s.Enum = []string{"debug", "info"}
+			return s
+		},
+	})
+
+	require.Equal(t, &jsonschema.Schema{
+		Schema: jsonschema.Draft,
+		Ref:    "#/$defs/Node",
+		Defs: map[string]*jsonschema.Schema{
+			"Node": {
+				Type: "object",
+				Properties: map[string]*jsonschema.Schema{
+					"name":     {Type: "string"},
+					"level":    {Type: "string", Enum: []string{"debug", "info"}},
+					"children": {Type: []string{"array", "null"}, Items: &jsonschema.Schema{Ref: "#/$defs/Node"}},
+					"labels":   {Type: []string{"object", "null"}, AdditionalProperties: &jsonschema.Schema{Type: "string"}},
+				},
+				Required:             []string{"name"},
+				AdditionalProperties: false,
+			},
+		},
+	}, res)
+}
@@ -0,0 +1,111 @@
+// Package procexec runs programs so that they behave as if they were invoked directly: signals reach the program,
+// and its exit code is preserved.
+package procexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotSupported is returned by Exec when the platform can not replace the current process.
+var ErrNotSupported = errors.New("process replacement is not supported")
+
+// waitDelay is a time that program has to exit after the context was canceled. The program is killed after that.
+const waitDelay = 10 * time.Second
+
+// Cmd describes a program to run.
+type Cmd struct {
+	// Path is a path to the binary.
+	Path string
+	Args []string
+	// Env is the whole environment of the program. Each item has KEY=VALUE format. The last value of duplicated key
+	// wins, so overrides can be appended to os.Environ().
+	Env []string
+	// Dir is a working dir. Empty means the current dir.
+	Dir string
+}
+
+// Run starts the program and waits for it. The program stays in the process group of toolset, so signals of the
+// terminal (Ctrl-C, job control) reach it directly and are ignored by toolset. Signals that are sent to toolset only
+// (like SIGTERM from CI) are forwarded. When ctx is canceled the program is asked to exit instead of being killed,
+// so it can stop its children.
+func Run(ctx context.Context, c Cmd) error {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Env = dedupEnv(c.Env)
+	cmd.Dir = c.Dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error { return terminate(cmd.Process) }
+	cmd.WaitDelay = waitDelay
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, slices.Concat(ignoredSignals, forwardedSignals)...)
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-sigs:
+				if slices.Contains(forwardedSignals, sig) {
+					_ = cmd.Process.Signal(sig)
+				}
+			}
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+// ExitCode returns an exit code of the program that was finished with error. Program that was killed by a signal
+// has the same code as reported by shells: 128 + signal number.
+func ExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+
+	if code, ok := signalExitCode(exitErr); ok {
+		return code, true
+	}
+
+	return exitErr.ExitCode(), true
+}
+
+// dedupEnv removes duplicated keys from env, keeping the last value at the position of the first one. os/exec does the
+// same, but syscall.Exec passes env as is, and programs usually read the first value.
+func dedupEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+
+	res := make([]string, 0, len(env))
+	seen := make(map[string]int, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if i, ok := seen[key]; ok {
+			res[i] = kv
+			continue
+		}
+
+		seen[key] = len(res)
+		res = append(res, kv)
+	}
+
+	return res
+}
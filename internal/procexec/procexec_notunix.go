@@ -0,0 +1,27 @@
+//go:build !unix
+
+package procexec
+
+import (
+	"os"
+	"os/exec"
+)
+
+// ignoredSignals are sent by console to all attached processes, so the program receives them directly.
+var ignoredSignals = []os.Signal{os.Interrupt}
+
+// NOTE: signals can not be sent to other processes on these platforms.
+var forwardedSignals []os.Signal
+
+// Exec always returns ErrNotSupported. Use Run instead.
+func Exec(Cmd) error {
+	return ErrNotSupported
+}
+
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+func signalExitCode(*exec.ExitError) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package procexec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ignoredSignals are sent by terminal to the whole process group, so the program receives them directly.
+var ignoredSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// Exec replaces the current process with the program. It returns only on error.
+func Exec(c Cmd) error {
+	if c.Dir != "" {
+		if err := os.Chdir(c.Dir); err != nil {
+			return fmt.Errorf("change dir: %w", err)
+		}
+	}
+
+	return syscall.Exec(c.Path, append([]string{c.Path}, c.Args...), dedupEnv(c.Env))
+}
+
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+func signalExitCode(exitErr *exec.ExitError) (int, bool) {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+
+	return 128 + int(status.Signal()), true
+}
@@ -0,0 +1,117 @@
+//go:build unix
+
+package procexec_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kazhuravlev/toolset/internal/procexec"
+	"github.com/stretchr/testify/require"
+)
+
+// trapTerm exits with code 7 on SIGTERM. Sleep is run in background, because sh handles traps only between commands.
+// It is killed on exit, so it does not hold stdout of the test.
+const trapTerm = `trap 'kill $!; exit 7' TERM; sleep 5 & wait`
+
+func sh(script string) procexec.Cmd {
+	return procexec.Cmd{
+		Path: "/bin/sh",
+		Args: []string{"-c", script},
+		Env:  os.Environ(),
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, procexec.Run(ctx, sh("exit 0")))
+	})
+
+	t.Run("exit_code", func(t *testing.T) {
+		code, ok := procexec.ExitCode(procexec.Run(ctx, sh("exit 3")))
+		require.True(t, ok)
+		require.Equal(t, 3, code)
+	})
+
+	t.Run("killed_by_signal", func(t *testing.T) {
+		code, ok := procexec.ExitCode(procexec.Run(ctx, sh("kill -KILL $$")))
+		require.True(t, ok)
+		require.Equal(t, 128+int(syscall.SIGKILL), code)
+	})
+
+	t.Run("forward_sigterm", func(t *testing.T) {
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}()
+
+		code, ok := procexec.ExitCode(procexec.Run(ctx, sh(trapTerm)))
+		require.True(t, ok)
+		require.Equal(t, 7, code)
+	})
+
+	t.Run("terminate_on_cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		defer cancel()
+
+		code, ok := procexec.ExitCode(procexec.Run(ctx, sh(trapTerm)))
+		require.True(t, ok)
+		require.Equal(t, 7, code)
+	})
+
+	t.Run("not_exit_error", func(t *testing.T) {
+		_, ok := procexec.ExitCode(procexec.Run(ctx, procexec.Cmd{Path: "/not/exists"}))
+		require.False(t, ok)
+	})
+}
+
+// envHelperMode makes TestHelperProcess run `env` with duplicated keys by Run or Exec. The helper is a separate
+// process, because Exec replaces it.
+const envHelperMode = "PROCEXEC_TEST_MODE"
+
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(envHelperMode)
+	if mode == "" {
+		t.Skip("helper process of TestDedupEnv")
+	}
+
+	envBin, err := exec.LookPath("env")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	cmd := procexec.Cmd{Path: envBin, Env: []string{"A=first", "B=b", "A=last"}}
+	if mode == "exec" {
+		err = procexec.Exec(cmd)
+	} else {
+		err = procexec.Run(context.Background(), cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func TestDedupEnv(t *testing.T) {
+	for _, mode := range []string{"run", "exec"} {
+		t.Run(mode, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+			cmd.Env = append(os.Environ(), envHelperMode+"="+mode)
+
+			out, err := cmd.Output()
+			require.NoError(t, err)
+			require.Equal(t, "A=last\nB=b\n", string(out))
+		})
+	}
+}
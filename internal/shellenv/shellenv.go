@@ -0,0 +1,127 @@
+// Package shellenv renders commands that activate project tools in a shell.
+package shellenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// EnvDirs keeps dirs that were added to PATH by the last activation. They are removed on the next one.
+const EnvDirs = "TOOLSET_PATH_DIRS"
+
+type Shell string
+
+const (
+	ShellBash Shell = "bash"
+	ShellZsh  Shell = "zsh"
+	ShellFish Shell = "fish"
+	// ShellDirenv is supported only by Hook.
+	ShellDirenv Shell = "direnv"
+)
+
+// ParseShell parses shell name.
+func ParseShell(s string) (Shell, error) {
+	switch shell := Shell(strings.ToLower(s)); shell {
+	case ShellBash, ShellZsh, ShellFish:
+		return shell, nil
+	}
+
+	return "", fmt.Errorf("unknown shell (%s): expected one of bash, zsh, fish", s)
+}
+
+// Var is an env variable. Empty value unsets the variable.
+type Var struct {
+	Name  string
+	Value string
+}
+
+// Activate returns PATH with dirs placed first. Dirs of the previous activation (prev) are removed.
+func Activate(path string, prev, dirs []string) string {
+	res := slices.Clone(dirs)
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" || slices.Contains(prev, dir) || slices.Contains(dirs, dir) {
+			continue
+		}
+
+		res = append(res, dir)
+	}
+
+	return strings.Join(res, string(os.PathListSeparator))
+}
+
+// Export renders commands that set variables in shell.
+func Export(shell Shell, vars []Var) string {
+	var sb strings.Builder
+	for _, v := range vars {
+		switch {
+		case shell == ShellFish && v.Value == "":
+			fmt.Fprintf(&sb, "set -e %s;\n", v.Name)
+		case shell == ShellFish && v.Name == "PATH":
+			// NOTE: PATH is a list in fish.
+			parts := filepath.SplitList(v.Value)
+			for i := range parts {
+				parts[i] = quote(parts[i])
+			}
+
+			fmt.Fprintf(&sb, "set -gx PATH %s;\n", strings.Join(parts, " "))
+		case shell == ShellFish:
+			fmt.Fprintf(&sb, "set -gx %s %s;\n", v.Name, quote(v.Value))
+		case v.Value == "":
+			fmt.Fprintf(&sb, "unset %s;\n", v.Name)
+		default:
+			fmt.Fprintf(&sb, "export %s=%s;\n", v.Name, quote(v.Value))
+		}
+	}
+
+	return sb.String()
+}
+
+// Hook returns a script that activates project tools when current dir is changed. For direnv it returns
+// `use_toolset` function for direnvrc.
+func Hook(shell Shell) (string, error) {
+	switch shell {
+	case ShellBash:
+		return `_toolset_hook() {
+  local previous_exit_status=$?
+  if [[ "${_TOOLSET_PWD:-}" != "$PWD" ]]; then
+    _TOOLSET_PWD="$PWD"
+    eval "$(toolset env --shell bash)"
+  fi
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_toolset_hook;"* ]]; then
+  PROMPT_COMMAND="_toolset_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`, nil
+	case ShellZsh:
+		return `_toolset_hook() {
+  eval "$(toolset env --shell zsh)"
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _toolset_hook
+_toolset_hook
+`, nil
+	case ShellFish:
+		return `function __toolset_hook --on-variable PWD
+  toolset env --shell fish | source
+end
+__toolset_hook
+`, nil
+	case ShellDirenv:
+		return `use_toolset() {
+  watch_file .toolset.*
+  eval "$(toolset env --shell bash)"
+}
+`, nil
+	}
+
+	return "", fmt.Errorf("unknown shell (%s): expected one of bash, zsh, fish, direnv", shell)
+}
+
+// quote returns a single-quoted string. It works for all supported shells.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
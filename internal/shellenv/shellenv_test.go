@@ -0,0 +1,70 @@
+package shellenv_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/shellenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	t.Run("prepend_dirs", func(t *testing.T) {
+		res := shellenv.Activate("/usr/bin:/bin", nil, []string{"/tools/a", "/tools/b"})
+		require.Equal(t, "/tools/a:/tools/b:/usr/bin:/bin", res)
+	})
+
+	t.Run("replace_previous_dirs", func(t *testing.T) {
+		res := shellenv.Activate("/tools/a:/usr/bin:/tools/b:/bin", []string{"/tools/a", "/tools/b"}, []string{"/tools/c"})
+		require.Equal(t, "/tools/c:/usr/bin:/bin", res)
+	})
+
+	t.Run("move_existing_dir_first", func(t *testing.T) {
+		res := shellenv.Activate("/usr/bin:/tools/a", nil, []string{"/tools/a"})
+		require.Equal(t, "/tools/a:/usr/bin", res)
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		res := shellenv.Activate("/tools/a:/usr/bin", []string{"/tools/a"}, nil)
+		require.Equal(t, "/usr/bin", res)
+	})
+}
+
+func TestExport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	vars := []shellenv.Var{
+		{Name: "PATH", Value: "/it's:/usr/bin"},
+		{Name: shellenv.EnvDirs, Value: ""},
+	}
+
+	require.Equal(t, "export PATH='/it'\\''s:/usr/bin';\nunset TOOLSET_PATH_DIRS;\n", shellenv.Export(shellenv.ShellBash, vars))
+	require.Equal(t, "export PATH='/it'\\''s:/usr/bin';\nunset TOOLSET_PATH_DIRS;\n", shellenv.Export(shellenv.ShellZsh, vars))
+	require.Equal(t, "set -gx PATH '/it'\\''s' '/usr/bin';\nset -e TOOLSET_PATH_DIRS;\n", shellenv.Export(shellenv.ShellFish, vars))
+}
+
+func TestParseShell(t *testing.T) {
+	shell, err := shellenv.ParseShell("ZSH")
+	require.NoError(t, err)
+	require.Equal(t, shellenv.ShellZsh, shell)
+
+	_, err = shellenv.ParseShell("direnv")
+	require.Error(t, err)
+}
+
+func TestHook(t *testing.T) {
+	for _, shell := range []shellenv.Shell{shellenv.ShellBash, shellenv.ShellZsh, shellenv.ShellFish, shellenv.ShellDirenv} {
+		script, err := shellenv.Hook(shell)
+		require.NoError(t, err)
+		require.Contains(t, script, "toolset env --shell")
+	}
+
+	_, err := shellenv.Hook("tcsh")
+	require.Error(t, err)
+}
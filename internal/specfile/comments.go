@@ -0,0 +1,426 @@
+package specfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Update encodes in into specified format and keeps comments of orig, which is a previous version of the same
+// document. Comments follow mapping keys; sequence items are matched by content first and by position after that.
+// Plain JSON has no comments, so it is the same as Marshal.
+func Update(format Format, orig []byte, in any) ([]byte, error) {
+	if format == FormatJSON || len(bytes.TrimSpace(orig)) == 0 {
+		return Marshal(format, in)
+	}
+
+	bb, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		var prev yaml.Node
+		if err := yaml.Unmarshal(orig, &prev); err != nil {
+			return nil, fmt.Errorf("parse yaml: %w", err)
+		}
+
+		var node yaml.Node
+		if err := yaml.Unmarshal(bb, &node); err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+
+		resetStyle(&node)
+		copyComments(&prev, &node)
+
+		return encodeYAML(&node)
+	default:
+		prev, err := parseJSONC(orig)
+		if err != nil {
+			return nil, fmt.Errorf("parse jsonc: %w", err)
+		}
+
+		node, err := parseJSONC(bb)
+		if err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+
+		copyComments(prev, node)
+
+		buf := bytes.NewBuffer(nil)
+		printJSONC(buf, node, 0)
+
+		return buf.Bytes(), nil
+	}
+}
+
+// copyComments copies comments from nodes of src to matching nodes of dst.
+func copyComments(src, dst *yaml.Node) {
+	if src.Kind != dst.Kind {
+		return
+	}
+
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+
+	switch dst.Kind {
+	case yaml.DocumentNode:
+		if len(src.Content) == 1 && len(dst.Content) == 1 {
+			copyComments(src.Content[0], dst.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if src.Content[j].Value != dst.Content[i].Value {
+					continue
+				}
+
+				copyComments(src.Content[j], dst.Content[i])
+				copyComments(src.Content[j+1], dst.Content[i+1])
+
+				break
+			}
+		}
+	case yaml.SequenceNode:
+		matched := make([]bool, len(src.Content))
+		pending := make([]*yaml.Node, 0, len(dst.Content))
+		for _, item := range dst.Content {
+			found := false
+			for j, srcItem := range src.Content {
+				if !matched[j] && equalNodes(srcItem, item) {
+					matched[j] = true
+					found = true
+					copyComments(srcItem, item)
+
+					break
+				}
+			}
+
+			if !found {
+				pending = append(pending, item)
+			}
+		}
+
+		// Items that were changed keep comments of the not matched items in the same order.
+		for j, srcItem := range src.Content {
+			if len(pending) == 0 {
+				break
+			}
+
+			if matched[j] {
+				continue
+			}
+
+			copyComments(srcItem, pending[0])
+			pending = pending[1:]
+		}
+	}
+}
+
+// equalNodes compares content of nodes. Comments and styles are ignored.
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func encodeYAML(node *yaml.Node) ([]byte, error) {
+	out := bytes.NewBuffer(nil)
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(node); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// jsoncParser reads JSONC into yaml.Node to keep the field order and comments. Comments are stored as is (with
+// `//` and `/* */`): comments before a field or an item are its HeadComment, comments on the same line after a value
+// are its LineComment and comments before the closing bracket are FootComment of the collection.
+type jsoncParser struct {
+	data []byte
+	pos  int
+}
+
+func parseJSONC(data []byte) (*yaml.Node, error) {
+	p := &jsoncParser{data: data}
+
+	head, _ := p.skip()
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	line, foot := p.skip()
+
+	if p.pos < len(p.data) {
+		return nil, fmt.Errorf("unexpected data at offset %d", p.pos)
+	}
+
+	return &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: join(head),
+		FootComment: join(append(line, foot...)),
+		Content:     []*yaml.Node{val},
+	}, nil
+}
+
+// skip skips spaces and comments. It returns comments that are on the same line as the previous token and
+// comments on the next lines.
+func (p *jsoncParser) skip() ([]string, []string) {
+	var line, next []string
+	sameLine := true
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '\n':
+			sameLine = false
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.data) && (p.data[p.pos+1] == '/' || p.data[p.pos+1] == '*'):
+			var end int
+			if p.data[p.pos+1] == '/' {
+				end = bytes.IndexByte(p.data[p.pos:], '\n')
+				if end == -1 {
+					end = len(p.data) - p.pos
+				}
+			} else {
+				end = bytes.Index(p.data[p.pos+2:], []byte("*/"))
+				if end == -1 {
+					end = len(p.data) - p.pos
+				} else {
+					end += 4
+				}
+			}
+
+			comment := strings.TrimRight(string(p.data[p.pos:p.pos+end]), " \t\r")
+			if sameLine {
+				line = append(line, comment)
+			} else {
+				next = append(next, comment)
+			}
+
+			p.pos += end
+		default:
+			return line, next
+		}
+	}
+
+	return line, next
+}
+
+func (p *jsoncParser) value() (*yaml.Node, error) {
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.collection(yaml.MappingNode, '}')
+	case c == '[':
+		return p.collection(yaml.SequenceNode, ']')
+	case c == '"':
+		end := skipString(p.data, p.pos)
+		if end >= len(p.data) {
+			return nil, fmt.Errorf("unterminated string at offset %d", p.pos)
+		}
+
+		var s string
+		if err := json.Unmarshal(p.data[p.pos:end+1], &s); err != nil {
+			return nil, fmt.Errorf("bad string at offset %d: %w", p.pos, err)
+		}
+
+		p.pos = end + 1
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.data) && !strings.ContainsRune(" \t\r\n,:]}/", rune(p.data[p.pos])) {
+			p.pos++
+		}
+
+		raw := string(p.data[start:p.pos])
+		var tag string
+		switch {
+		case raw == "true" || raw == "false":
+			tag = "!!bool"
+		case raw == "null":
+			tag = "!!null"
+		case json.Valid([]byte(raw)) && strings.ContainsAny(raw, ".eE"):
+			tag = "!!float"
+		case json.Valid([]byte(raw)):
+			tag = "!!int"
+		default:
+			return nil, fmt.Errorf("unexpected value at offset %d", start)
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: raw}, nil
+	}
+}
+
+func (p *jsoncParser) collection(kind yaml.Kind, closing byte) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: kind}
+	p.pos++
+
+	line, head := p.skip()
+	node.LineComment = join(line)
+
+	for {
+		if p.pos < len(p.data) && p.data[p.pos] == closing {
+			p.pos++
+			node.FootComment = join(head)
+
+			return node, nil
+		}
+
+		var key *yaml.Node
+		if kind == yaml.MappingNode {
+			var err error
+			if key, err = p.value(); err != nil {
+				return nil, err
+			}
+
+			if key.Kind != yaml.ScalarNode || key.Tag != "!!str" {
+				return nil, fmt.Errorf("expected a key at offset %d", p.pos)
+			}
+
+			p.skip()
+			if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+				return nil, fmt.Errorf("expected ':' at offset %d", p.pos)
+			}
+
+			p.pos++
+			p.skip()
+		}
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+
+		line, next := p.skip()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+
+			afterComma, rest := p.skip()
+			line = append(line, afterComma...)
+			next = append(next, rest...)
+		} else if p.pos >= len(p.data) || p.data[p.pos] != closing {
+			return nil, fmt.Errorf("expected ',' or '%c' at offset %d", closing, p.pos)
+		}
+
+		if len(line) != 0 {
+			val.LineComment = join(line)
+		}
+
+		if key != nil {
+			key.HeadComment = join(head)
+			node.Content = append(node.Content, key, val)
+		} else {
+			val.HeadComment = join(head)
+			node.Content = append(node.Content, val)
+		}
+
+		head = next
+	}
+}
+
+// printJSONC writes node in the same layout as Marshal does, with comments.
+func printJSONC(buf *bytes.Buffer, node *yaml.Node, depth int) {
+	indent := func(depth int) string { return strings.Repeat("\t", depth) }
+	comments := func(text string, depth int) {
+		if text == "" {
+			return
+		}
+
+		for _, line := range strings.Split(text, "\n") {
+			buf.WriteString(indent(depth) + line + "\n")
+		}
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		comments(node.HeadComment, 0)
+		for _, child := range node.Content {
+			printJSONC(buf, child, 0)
+		}
+
+		buf.WriteString("\n")
+		comments(node.FootComment, 0)
+	case yaml.MappingNode, yaml.SequenceNode:
+		opening, closing, step := "{", "}", 2
+		if node.Kind == yaml.SequenceNode {
+			opening, closing, step = "[", "]", 1
+		}
+
+		if len(node.Content) == 0 && node.LineComment == "" && node.FootComment == "" {
+			buf.WriteString(opening + closing)
+
+			return
+		}
+
+		buf.WriteString(opening)
+		if node.LineComment != "" {
+			buf.WriteString(" " + node.LineComment)
+		}
+
+		buf.WriteString("\n")
+
+		for i := 0; i < len(node.Content); i += step {
+			first, val := node.Content[i], node.Content[i+step-1]
+
+			comments(first.HeadComment, depth+1)
+			buf.WriteString(indent(depth + 1))
+			if step == 2 {
+				printJSONC(buf, first, depth+1)
+				buf.WriteString(": ")
+			}
+
+			printJSONC(buf, val, depth+1)
+			if i+step < len(node.Content) {
+				buf.WriteString(",")
+			}
+
+			if val.Kind == yaml.ScalarNode && val.LineComment != "" {
+				buf.WriteString(" " + val.LineComment)
+			}
+
+			buf.WriteString("\n")
+		}
+
+		comments(node.FootComment, depth+1)
+		buf.WriteString(indent(depth) + closing)
+	default:
+		if node.Tag != "!!str" {
+			buf.WriteString(node.Value)
+
+			return
+		}
+
+		bb, _ := json.Marshal(node.Value)
+		buf.Write(bb)
+	}
+}
+
+func join(comments []string) string {
+	return strings.Join(comments, "\n")
+}
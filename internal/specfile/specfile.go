@@ -0,0 +1,308 @@
+// Package specfile reads and writes spec files in JSON, JSONC and YAML formats.
+//
+// All formats are converted to JSON before decoding, so types keep using their json tags and json.Unmarshaler
+// implementations.
+package specfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatJSONC Format = "jsonc"
+	FormatYAML  Format = "yaml"
+	// FormatAuto detects format by content. Used for remote includes.
+	FormatAuto Format = "auto"
+)
+
+// Formats returns all formats that can be used for spec files.
+func Formats() []Format {
+	return []Format{FormatJSON, FormatJSONC, FormatYAML}
+}
+
+// ParseFormat parses format name.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "json":
+		return FormatJSON, nil
+	case "jsonc":
+		return FormatJSONC, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	}
+
+	return "", fmt.Errorf("unknown format (%s): expected one of json, jsonc, yaml", s)
+}
+
+// Ext returns a file extension for format.
+func (f Format) Ext() string {
+	switch f {
+	case FormatJSONC:
+		return ".jsonc"
+	case FormatYAML:
+		return ".yaml"
+	default:
+		return ".json"
+	}
+}
+
+// FormatOf returns a format of file by its extension.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonc":
+		return FormatJSONC
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatJSON
+	}
+}
+
+// Unmarshal decodes data in specified format into out.
+func Unmarshal(format Format, data []byte, out any) error {
+	bb, err := ToJSON(format, data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bb, out)
+}
+
+// ToJSON converts data in specified format to JSON.
+func ToJSON(format Format, data []byte) ([]byte, error) {
+	if format == FormatAuto {
+		format = detect(data)
+	}
+
+	switch format {
+	case FormatJSONC:
+		return StripJSONC(data), nil
+	case FormatYAML:
+		return yamlToJSON(data)
+	default:
+		return data, nil
+	}
+}
+
+// Marshal encodes in into specified format. JSONC is written as plain JSON, use Update to keep comments.
+func Marshal(format Format, in any) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "\t")
+
+	if err := enc.Encode(in); err != nil {
+		return nil, err
+	}
+
+	if format != FormatYAML {
+		return buf.Bytes(), nil
+	}
+
+	// Decode JSON as a YAML node to keep the field order.
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &node); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	resetStyle(&node)
+
+	return encodeYAML(&node)
+}
+
+// ReadJSON reads file in format that matches its extension and converts it to JSON.
+func ReadJSON(ctx context.Context, fs fsh.FS, path string) ([]byte, error) {
+	{
+		unlock, err := fs.RLock(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("rlock file: %w", err)
+		}
+
+		defer unlock()
+	}
+
+	bb, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("read file (%s): %w", path, err)
+	}
+
+	res, err := ToJSON(FormatOf(path), bb)
+	if err != nil {
+		return nil, fmt.Errorf("parse file (%s): %w", path, err)
+	}
+
+	return res, nil
+}
+
+// Write writes file in format that matches its extension. Comments of the existing file are kept (see Update).
+func Write(ctx context.Context, fs fsh.FS, in any, path string) error {
+	{
+		unlock, err := fs.Lock(ctx, path)
+		if err != nil {
+			return fmt.Errorf("lock file: %w", err)
+		}
+
+		defer unlock()
+	}
+
+	orig, err := afero.ReadFile(fs, path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	bb, err := Update(FormatOf(path), orig, in)
+	if err != nil {
+		return fmt.Errorf("marshal file: %w", err)
+	}
+
+	if err := afero.WriteFile(fs, path, bb, 0o644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
+
+// detect returns JSONC for documents that look like JSON and YAML otherwise.
+func detect(data []byte) Format {
+	data = bytes.TrimSpace(data)
+	for _, prefix := range []string{"{", "[", "//", "/*"} {
+		if bytes.HasPrefix(data, []byte(prefix)) {
+			return FormatJSONC
+		}
+	}
+
+	return FormatYAML
+}
+
+// StripJSONC removes comments and trailing commas. Removed characters are replaced with spaces to keep offsets
+// in error messages.
+func StripJSONC(data []byte) []byte {
+	res := bytes.Clone(data)
+
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(res); i++ {
+			if res[i] != '\n' {
+				res[i] = ' '
+			}
+		}
+	}
+
+	// Comments.
+	for i := 0; i < len(res); i++ {
+		switch {
+		case res[i] == '"':
+			i = skipString(res, i)
+		case res[i] == '/' && i+1 < len(res) && res[i+1] == '/':
+			end := bytes.IndexByte(res[i:], '\n')
+			if end == -1 {
+				end = len(res) - i
+			}
+
+			blank(i, i+end)
+			i += end
+		case res[i] == '/' && i+1 < len(res) && res[i+1] == '*':
+			end := bytes.Index(res[i+2:], []byte("*/"))
+			if end == -1 {
+				end = len(res) - i - 2
+			}
+
+			blank(i, i+2+end+2)
+			i += 2 + end + 1
+		}
+	}
+
+	// Trailing commas.
+	for i := 0; i < len(res); i++ {
+		switch res[i] {
+		case '"':
+			i = skipString(res, i)
+		case ',':
+			next := i + 1
+			for next < len(res) && strings.ContainsRune(" \t\r\n", rune(res[next])) {
+				next++
+			}
+
+			if next < len(res) && (res[next] == '}' || res[next] == ']') {
+				res[i] = ' '
+			}
+		}
+	}
+
+	return res
+}
+
+// skipString returns the position of closing quote for string that starts at pos.
+func skipString(data []byte, pos int) int {
+	for i := pos + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return len(data)
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+
+	bb, err := json.Marshal(normalizeYAML(doc))
+	if err != nil {
+		return nil, fmt.Errorf("convert yaml: %w", err)
+	}
+
+	return bb, nil
+}
+
+// normalizeYAML converts maps with non-string keys, which are not supported by encoding/json.
+func normalizeYAML(val any) any {
+	switch val := val.(type) {
+	case map[string]any:
+		for k, v := range val {
+			val[k] = normalizeYAML(v)
+		}
+
+		return val
+	case map[any]any:
+		res := make(map[string]any, len(val))
+		for k, v := range val {
+			res[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+
+		return res
+	case []any:
+		for i := range val {
+			val[i] = normalizeYAML(val[i])
+		}
+
+		return val
+	default:
+		return val
+	}
+}
+
+// resetStyle drops JSON-specific styles (flow collections, quoted strings) from node.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -0,0 +1,129 @@
+package specfile_test
+
+import (
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/stretchr/testify/require"
+)
+
+type doc struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Count int      `json:"count,omitempty"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	f := func(name string, format specfile.Format, in string, exp doc) {
+		t.Run(name, func(t *testing.T) {
+			var res doc
+			require.NoError(t, specfile.Unmarshal(format, []byte(in), &res))
+			require.Equal(t, exp, res)
+		})
+	}
+
+	exp := doc{Name: "a // b", Tags: []string{"x", "y"}}
+
+	f("json", specfile.FormatJSON, `{"name": "a // b", "tags": ["x", "y"]}`, exp)
+	f("jsonc", specfile.FormatJSONC, `{
+	// line comment
+	"name": "a // b", /* block
+	comment */
+	"tags": ["x", "y",],
+}`, exp)
+	f("yaml", specfile.FormatYAML, "name: a // b\ntags:\n  - x\n  - y\n", exp)
+	f("auto_json", specfile.FormatAuto, `{"name": "a // b", "tags": ["x", "y"]}`, exp)
+	f("auto_jsonc", specfile.FormatAuto, "// comment\n{\"name\": \"a // b\", \"tags\": [\"x\", \"y\"]}", exp)
+	f("auto_yaml", specfile.FormatAuto, "# comment\nname: a // b\ntags: [x, y]\n", exp)
+}
+
+func TestMarshal(t *testing.T) {
+	in := doc{Name: "true", Tags: []string{"v1.0"}, Count: 2}
+
+	bb, err := specfile.Marshal(specfile.FormatYAML, in)
+	require.NoError(t, err)
+	require.Equal(t, "name: \"true\"\ntags:\n  - v1.0\ncount: 2\n", string(bb))
+
+	var res doc
+	require.NoError(t, specfile.Unmarshal(specfile.FormatYAML, bb, &res))
+	require.Equal(t, in, res)
+
+	bb, err = specfile.Marshal(specfile.FormatJSONC, in)
+	require.NoError(t, err)
+	require.Equal(t, "{\n\t\"name\": \"true\",\n\t\"tags\": [\n\t\t\"v1.0\"\n\t],\n\t\"count\": 2\n}\n", string(bb))
+}
+
+func TestFormatOf(t *testing.T) {
+	require.Equal(t, specfile.FormatJSON, specfile.FormatOf("/dir/.toolset.json"))
+	require.Equal(t, specfile.FormatJSONC, specfile.FormatOf("/dir/.toolset.jsonc"))
+	require.Equal(t, specfile.FormatYAML, specfile.FormatOf("/dir/.toolset.yaml"))
+	require.Equal(t, specfile.FormatYAML, specfile.FormatOf("/dir/.toolset.yml"))
+}
+
+func TestUpdate(t *testing.T) {
+	f := func(name string, format specfile.Format, orig string, in doc, exp string) {
+		t.Run(name, func(t *testing.T) {
+			bb, err := specfile.Update(format, []byte(orig), in)
+			require.NoError(t, err)
+			require.Equal(t, exp, string(bb))
+
+			var res doc
+			require.NoError(t, specfile.Unmarshal(format, bb, &res))
+			require.Equal(t, in, res)
+		})
+	}
+
+	in := doc{Name: "new", Tags: []string{"x", "z", "y2"}}
+
+	f("yaml", specfile.FormatYAML, `# Project tools.
+
+# Name of the project.
+name: old # keep it short
+tags:
+  # first
+  - x
+  # removed
+  - y
+  # changed
+  - y1 # was y1
+`, in, `# Project tools.
+
+# Name of the project.
+name: new # keep it short
+tags:
+  # first
+  - x
+  # removed
+  - z
+  # changed
+  - y2 # was y1
+`)
+	f("jsonc", specfile.FormatJSONC, `// Project tools.
+{
+	// Name of the project.
+	"name": "old", // keep it short
+	"tags": [ // tags
+		// first
+		"x",
+		/* changed */
+		"y",
+		// no more tags
+	],
+}
+`, doc{Name: "new", Tags: []string{"x", "y2"}}, `// Project tools.
+{
+	// Name of the project.
+	"name": "new", // keep it short
+	"tags": [ // tags
+		// first
+		"x",
+		/* changed */
+		"y2"
+		// no more tags
+	]
+}
+`)
+	f("jsonc_without_comments", specfile.FormatJSONC, `{"name": "old"}`, in,
+		"{\n\t\"name\": \"new\",\n\t\"tags\": [\n\t\t\"x\",\n\t\t\"z\",\n\t\t\"y2\"\n\t]\n}\n")
+	f("new_file", specfile.FormatYAML, "", in, "name: new\ntags:\n  - x\n  - z\n  - y2\n")
+}
@@ -1,8 +1,10 @@
 package timeh
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -62,3 +64,33 @@ func Duration(d time.Duration) string {
 
 	return result
 }
+
+// ParseDuration works like time.ParseDuration, but also supports days (`d` suffix). Example: 7d, 1d12h, 36h.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+
+	var days time.Duration
+	if before, after, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(before)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration (%s): bad number of days", s)
+		}
+
+		days = time.Duration(n) * 24 * time.Hour
+		s = after
+	}
+
+	if s == "" {
+		return days, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+
+	return days + d, nil
+}
@@ -56,3 +56,33 @@ func TestDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		f := func(in string, exp time.Duration) {
+			res, err := ParseDuration(in)
+			require.NoError(t, err, in)
+			require.Equal(t, exp, res, in)
+		}
+
+		f("0", 0)
+		f("7d", 7*24*time.Hour)
+		f("1d12h", 36*time.Hour)
+		f("36h", 36*time.Hour)
+		f(" 2d ", 48*time.Hour)
+		f("90m", 90*time.Minute)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		f := func(in string) {
+			_, err := ParseDuration(in)
+			require.Error(t, err, in)
+		}
+
+		f("")
+		f("d")
+		f("-1d")
+		f("7days")
+		f("week")
+	})
+}
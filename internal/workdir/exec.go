@@ -0,0 +1,104 @@
+package workdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kazhuravlev/toolset/internal/procexec"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+// SetToolsOnPath makes RunTool and RunScript put binaries of all installed tools (filtered by tags) first on PATH of
+// the tool. Tools that call other tools (like buf with protoc plugins) get locked versions in this case.
+func (c *Workdir) SetToolsOnPath(enabled bool, tags []string) {
+	c.toolsOnPath = enabled
+	c.toolsOnPathTags = tags
+}
+
+// Exec runs an arbitrary command from the current dir. Binaries of all installed tools (filtered by tags) are placed
+// first on PATH, so the command and its children see locked versions of tools.
+func (c *Workdir) Exec(ctx context.Context, tags []string, name string, args ...string) error {
+	dirs, env, err := c.toolsPathEnv(ctx, tags)
+	if err != nil {
+		return err
+	}
+
+	path, err := lookPath(dirs, name)
+	if err != nil {
+		return fmt.Errorf("find command (%s): %w", name, err)
+	}
+
+	cmd := procexec.Cmd{
+		Path: path,
+		Args: args,
+		Env:  append(os.Environ(), env),
+	}
+
+	if c.replace {
+		if err := procexec.Exec(cmd); !errors.Is(err, procexec.ErrNotSupported) {
+			return fmt.Errorf("exec (%s): %w", name, err)
+		}
+	}
+
+	if err := procexec.Run(ctx, cmd); err != nil {
+		if code, ok := procexec.ExitCode(err); ok {
+			return fmt.Errorf("exit not ok (%s): %w", name, errors.Join(structs.RunError{ExitCode: code}, err))
+		}
+
+		return fmt.Errorf("exec (%s): %w", name, err)
+	}
+
+	return nil
+}
+
+// toolsPathEnv returns dirs of tool binaries and PATH env where these dirs are placed first. Tools that are not
+// installed are reported to stderr, because stdout belongs to the command.
+func (c *Workdir) toolsPathEnv(ctx context.Context, tags []string) ([]string, string, error) {
+	tools := c.tools()
+	dirs, missing, err := c.binDirs(ctx, tools.Filter(tags))
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, tool := range missing {
+		fmt.Fprintln(os.Stderr, "Not installed:", tool.Runtime, tool.Module, "(run `toolset sync`)")
+	}
+
+	// NOTE: empty item of PATH means the current dir, so empty PATH is not appended.
+	path := dirs
+	if env := os.Getenv("PATH"); env != "" {
+		path = append(slices.Clone(dirs), env)
+	}
+
+	return dirs, "PATH=" + strings.Join(path, string(os.PathListSeparator)), nil
+}
+
+// lookPath finds the program in dirs of tools first and in PATH of the process after that.
+func lookPath(dirs []string, name string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) || strings.ContainsRune(name, '/') {
+		return name, nil
+	}
+
+	candidates := []string{name}
+	if runtime.GOOS == "windows" {
+		candidates = append(candidates, name+".exe")
+	}
+
+	for _, dir := range dirs {
+		for _, candidate := range candidates {
+			path := filepath.Join(dir, candidate)
+			if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+				return path, nil
+			}
+		}
+	}
+
+	return exec.LookPath(name)
+}
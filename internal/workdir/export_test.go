@@ -0,0 +1,8 @@
+package workdir
+
+import "github.com/kazhuravlev/toolset/internal/workdir/runtimes"
+
+// SetRuntime registers rt in runtimes of workdir. Workspace projects that are opened by workdir use it too.
+func (c *Workdir) SetRuntime(rt runtimes.IRuntime) {
+	c.runtimes.Register(rt)
+}
@@ -0,0 +1,140 @@
+package workdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	remotes2 "github.com/kazhuravlev/toolset/internal/workdir/remotes"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+var ErrIncludeNotFound = errors.New("include not found")
+
+// IncludeInfo describes include of project spec.
+type IncludeInfo struct {
+	Include structs.Include
+	// Remotes are the include itself and all nested includes.
+	Remotes []structs.RemoteSpec
+	// Tools are locked tools that are provided by this include.
+	Tools structs.Tools
+}
+
+// ToolsDiff describes changes of locked tools.
+type ToolsDiff struct {
+	Added   structs.Tools
+	Removed structs.Tools
+	Changed []ToolChange
+}
+
+type ToolChange struct {
+	From structs.Tool
+	To   structs.Tool
+}
+
+func (d ToolsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ListIncludes returns includes of project spec.
+func (c *Workdir) ListIncludes() []IncludeInfo {
+	res := make([]IncludeInfo, 0, len(c.spec.Includes))
+	for _, inc := range c.spec.Includes {
+		info := IncludeInfo{Include: inc}
+		for _, remote := range c.lock.Remotes {
+			if remote.Root() != inc.Src {
+				continue
+			}
+
+			info.Remotes = append(info.Remotes, remote)
+
+			for _, tool := range c.lock.Tools {
+				if tool.Source == remote.Source {
+					info.Tools = append(info.Tools, tool)
+				}
+			}
+		}
+
+		res = append(res, info)
+	}
+
+	return res
+}
+
+// RemoveInclude removes include from spec and its remotes from lock.
+func (c *Workdir) RemoveInclude(src string) (*ToolsDiff, error) {
+	if !c.spec.RemoveInclude(src) {
+		return nil, fmt.Errorf("remove include (%s): %w", src, ErrIncludeNotFound)
+	}
+
+	return c.replaceRemotes(src, nil)
+}
+
+// RefreshInclude re-fetches include and rebuilds lock only for this include. Tools of project spec are not
+// upgraded.
+func (c *Workdir) RefreshInclude(ctx context.Context, src string) (*ToolsDiff, error) {
+	inc := c.spec.FindInclude(src)
+	if !slices.ContainsFunc(c.spec.Includes, inc.IsSame) {
+		return nil, fmt.Errorf("refresh include (%s): %w", src, ErrIncludeNotFound)
+	}
+
+	remotes, err := c.fetcher.FetchRemote(ctx, inc.Src, inc.Tags, remotes2.FetchOpts{Revalidate: true})
+	if err != nil {
+		return nil, fmt.Errorf("fetch remotes: %w", err)
+	}
+
+	for _, remote := range remotes {
+		c.printRemoteChanges(remote)
+	}
+
+	return c.replaceRemotes(src, remotes)
+}
+
+// replaceRemotes replaces all remotes of include and rebuilds lock tools.
+func (c *Workdir) replaceRemotes(src string, remotes []structs.RemoteSpec) (*ToolsDiff, error) {
+	before := slices.Clone(c.lock.Tools)
+
+	resRemotes := make([]structs.RemoteSpec, 0, len(c.lock.Remotes)+len(remotes))
+	for _, remote := range c.lock.Remotes {
+		if remote.Root() == src {
+			// NOTE: keep the include position to not change the conflict resolution.
+			resRemotes = append(resRemotes, remotes...)
+			remotes = nil
+			continue
+		}
+
+		resRemotes = append(resRemotes, remote)
+	}
+
+	c.lock.Remotes = append(resRemotes, remotes...)
+
+	if err := c.refreshLock(); err != nil {
+		return nil, err
+	}
+
+	diff := diffTools(before, c.lock.Tools)
+
+	return &diff, nil
+}
+
+func diffTools(before, after structs.Tools) ToolsDiff {
+	var res ToolsDiff
+	for _, tool := range after {
+		idx := slices.IndexFunc(before, tool.IsSame)
+		switch {
+		case idx == -1:
+			res.Added = append(res.Added, tool)
+		case before[idx].Module != tool.Module || before[idx].Source != tool.Source:
+			res.Changed = append(res.Changed, ToolChange{From: before[idx], To: tool})
+		}
+	}
+
+	for _, tool := range before {
+		if !slices.ContainsFunc(after, tool.IsSame) {
+			res.Removed = append(res.Removed, tool)
+		}
+	}
+
+	return res
+}
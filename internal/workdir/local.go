@@ -0,0 +1,243 @@
+package workdir
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
+	"github.com/kazhuravlev/toolset/internal/workdir/runtimes"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+// localSpec is an untracked spec that is merged on top of the project spec. Its tools are locked into a separate
+// lock file, so they never get into the shared lock.
+type localSpec struct {
+	spec *structs.Spec
+	raw  []byte
+	lock *structs.Lock
+}
+
+// readLocal reads local spec and its lock. Returns nil when there is no local spec.
+func readLocal(ctx context.Context, fs fsh.FS, locations *Locations) (*localSpec, error) {
+	if !fsh.IsExists(fs, locations.LocalFile) {
+		return nil, nil
+	}
+
+	specJSON, err := specfile.ReadJSON(ctx, fs, locations.LocalFile)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, _, err := migrations.Spec(specJSON)
+	if err != nil {
+		return nil, fmt.Errorf("read local spec (%s): %w", locations.LocalFile, err)
+	}
+
+	raw, err := specfile.Marshal(specfile.FormatOf(locations.LocalFile), spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal local spec: %w", err)
+	}
+
+	lock := &structs.Lock{
+		Version: structs.LockVersion,
+		Tools:   make(structs.Tools, 0),
+		Remotes: make([]structs.RemoteSpec, 0),
+	}
+	if fsh.IsExists(fs, locations.LocalLockFile) {
+		lockJSON, err := specfile.ReadJSON(ctx, fs, locations.LocalLockFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if lock, _, err = migrations.Lock(lockJSON); err != nil {
+			return nil, fmt.Errorf("read local lock (%s): %w", locations.LocalLockFile, err)
+		}
+	}
+
+	return &localSpec{spec: spec, raw: raw, lock: lock}, nil
+}
+
+// SetLocal makes Add and Ensure write tools into the local spec. Local spec is created on Save when it does not
+// exist.
+func (c *Workdir) SetLocal(enabled bool) {
+	c.writeLocal = enabled
+	if enabled && c.local == nil {
+		c.local = &localSpec{
+			spec: &structs.Spec{
+				Version:  structs.SpecVersion,
+				Tools:    make(structs.Tools, 0),
+				Includes: make([]structs.Include, 0),
+			},
+			lock: &structs.Lock{
+				Version: structs.LockVersion,
+				Tools:   make(structs.Tools, 0),
+				Remotes: make([]structs.RemoteSpec, 0),
+			},
+		}
+	}
+}
+
+// targetSpec returns a spec that is changed by Add and Ensure.
+func (c *Workdir) targetSpec() *structs.Spec {
+	if c.writeLocal {
+		return c.local.spec
+	}
+
+	return c.spec
+}
+
+// localSource is a source of tools that are declared in local spec.
+func (c *Workdir) localSource() string {
+	return filepath.Base(c.locations.LocalFile)
+}
+
+// tools returns all locked tools. Tools of local lock have a priority over tools of shared lock.
+func (c *Workdir) tools() structs.Tools {
+	if c.local == nil {
+		return c.lock.Tools
+	}
+
+	res := slices.Clone(c.local.lock.Tools)
+	for _, tool := range c.lock.Tools {
+		if !slices.ContainsFunc(res, tool.IsSame) {
+			res = append(res, tool)
+		}
+	}
+
+	return res
+}
+
+// resolveLocalLock returns tools of local lock. Entries that override a tool of the shared lock are resolved against
+// it, see overrideTool.
+func (c *Workdir) resolveLocalLock() structs.Tools {
+	res := make(structs.Tools, 0, len(c.local.spec.Tools))
+	for _, tool := range c.local.spec.Tools {
+		if idx := slices.IndexFunc(c.lock.Tools, tool.IsSame); idx != -1 {
+			tool = overrideTool(c.lock.Tools[idx], tool)
+		}
+
+		tool.Source = c.localSource()
+		res = append(res, tool)
+	}
+
+	return res
+}
+
+// overrideTool returns the locked tool with fields that are set by the local entry. Entry without version keeps the
+// locked version, so it can change only an alias or env of the shared tool.
+func overrideTool(locked, local structs.Tool) structs.Tool {
+	res := locked
+	res.Runtime = local.Runtime
+	if local.ModuleVersion() != "" {
+		res.Module = local.Module
+	}
+
+	if local.Alias.HasVal() {
+		res.Alias = local.Alias
+	}
+
+	if local.Tags != nil {
+		res.Tags = local.Tags
+	}
+
+	if local.Hold {
+		res.Hold = true
+		res.HoldReason = local.HoldReason
+	}
+
+	if local.Env != nil {
+		res.Env = local.Env
+	}
+
+	if len(local.Args.Prepend) != 0 || len(local.Args.Append) != 0 {
+		res.Args = local.Args
+	}
+
+	if local.Platforms != nil {
+		res.Platforms = local.Platforms
+	}
+
+	return res
+}
+
+// saveLocal writes local spec (only when it was changed) and local lock.
+func (c *Workdir) saveLocal(ctx context.Context) error {
+	if c.local == nil {
+		return nil
+	}
+
+	bb, err := specfile.Marshal(specfile.FormatOf(c.locations.LocalFile), c.local.spec)
+	if err != nil {
+		return fmt.Errorf("marshal local spec: %w", err)
+	}
+
+	if !bytes.Equal(bb, c.local.raw) {
+		if err := specfile.Write(ctx, c.fs, c.local.spec, c.locations.LocalFile); err != nil {
+			return fmt.Errorf("write local spec: %w", err)
+		}
+
+		c.local.raw = bb
+	}
+
+	if err := fsh.WriteJson(ctx, c.fs, *c.local.lock, c.locations.LocalLockFile); err != nil {
+		return fmt.Errorf("write local lock: %w", err)
+	}
+
+	return nil
+}
+
+// validateLocal checks local spec and its lock. Only tools are used from local spec.
+func (c *Workdir) validateLocal() []Problem {
+	if c.local == nil {
+		return nil
+	}
+
+	specFile := filepath.Base(c.locations.LocalFile)
+	lockFile := filepath.Base(c.locations.LocalLockFile)
+
+	var res []Problem
+	add := func(file, path, msg string, args ...any) {
+		res = append(res, Problem{File: file, Path: path, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if fsh.IsExists(c.fs, c.locations.LocalFile) {
+		if err := decodeStrict(c.fs, c.locations.LocalFile, new(structs.Spec), structs.SpecVersion); err != nil {
+			add(specFile, "", "%s", err)
+		}
+	}
+
+	if fsh.IsExists(c.fs, c.locations.LocalLockFile) {
+		if err := decodeStrict(c.fs, c.locations.LocalLockFile, new(structs.Lock), structs.LockVersion); err != nil {
+			add(lockFile, "", "%s", err)
+		}
+	}
+
+	spec := c.local.spec
+	if len(spec.Includes) != 0 || len(spec.Scripts) != 0 || len(spec.Profiles) != 0 || spec.Workspace != nil ||
+		spec.ConflictPolicy != "" || spec.MinReleaseAge != "" {
+		add(specFile, "", "only tools are used from local spec, other fields are ignored")
+	}
+
+	resolved := c.resolveLocalLock()
+	for i, tool := range spec.Tools {
+		path := fmt.Sprintf("tools[%d]", i)
+		if _, err := runtimes.ParseModule(resolved[i].Runtime, resolved[i].Module); err != nil {
+			add(specFile, path, "%s", err)
+		}
+
+		if j := slices.IndexFunc(spec.Tools[:i], tool.IsSame); j != -1 {
+			add(specFile, path, "duplicates tools[%d]", j)
+		}
+	}
+
+	for _, msg := range lockMismatches(resolved, c.local.lock.Tools) {
+		add(lockFile, "", "%s. Run `toolset sync`", msg)
+	}
+
+	return res
+}
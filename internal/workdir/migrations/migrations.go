@@ -0,0 +1,172 @@
+// Package migrations upgrades spec and lock documents to the current format version.
+//
+// Migrations work with raw JSON documents, because old formats can not be represented by current structs. Each
+// migration upgrades a document to its Version. Add a new migration to the end of the registry and bump
+// structs.SpecVersion (or structs.LockVersion).
+package migrations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported format version")
+
+type Doc = map[string]any
+
+// Migration upgrades a document to Version.
+type Migration struct {
+	// Version is a format version after this migration.
+	Version int
+	// Apply changes the document in place and returns a list of human-readable changes.
+	Apply func(doc Doc) ([]string, error)
+}
+
+// Change describes one change that was made by migration.
+type Change struct {
+	Version int
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("v%d: %s", c.Version, c.Message)
+}
+
+// Spec decodes spec document and upgrades it to the current version.
+func Spec(bb []byte) (*structs.Spec, []Change, error) {
+	return decode[structs.Spec](bb, SpecMigrations(), structs.SpecVersion, nil)
+}
+
+// Lock decodes lock document and upgrades it to the current version. Specs of remotes are upgraded to the current
+// spec version even when the lock itself is up to date.
+func Lock(bb []byte) (*structs.Lock, []Change, error) {
+	return decode[structs.Lock](bb, LockMigrations(), structs.LockVersion, migrateRemoteSpecs)
+}
+
+// migrateRemoteSpecs upgrades specs that are embedded into remotes of the lock.
+func migrateRemoteSpecs(doc Doc) ([]Change, error) {
+	var res []Change
+
+	remotes, _ := doc["remotes"].([]any)
+	for _, remote := range remotes {
+		remote, ok := remote.(Doc)
+		if !ok {
+			continue
+		}
+
+		spec, ok := remote["spec"].(Doc)
+		if !ok {
+			continue
+		}
+
+		changes, err := Migrate(spec, SpecMigrations(), structs.SpecVersion)
+		if err != nil {
+			return nil, fmt.Errorf("migrate spec of remote (%v): %w", remote["source"], err)
+		}
+
+		for _, change := range changes {
+			change.Message = fmt.Sprintf("remote (%v): %s", remote["source"], change.Message)
+			res = append(res, change)
+		}
+	}
+
+	return res, nil
+}
+
+// Version returns a format version of document. Documents without version have version 0.
+func Version(bb []byte) (int, error) {
+	var doc Doc
+	if err := json.Unmarshal(bb, &doc); err != nil {
+		return 0, fmt.Errorf("unmarshal document: %w", err)
+	}
+
+	return docVersion(doc)
+}
+
+// decode migrates the document and decodes it into T. Nested migrates documents that are embedded into this one.
+func decode[T any](bb []byte, migrations []Migration, current int, nested func(Doc) ([]Change, error)) (*T, []Change, error) {
+	var doc Doc
+	if err := json.Unmarshal(bb, &doc); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal document: %w", err)
+	}
+
+	changes, err := Migrate(doc, migrations, current)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if nested != nil {
+		nestedChanges, err := nested(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changes = append(changes, nestedChanges...)
+	}
+
+	bb, err = json.Marshal(doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal document: %w", err)
+	}
+
+	var res T
+	if err := json.Unmarshal(bb, &res); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal migrated document: %w", err)
+	}
+
+	return &res, changes, nil
+}
+
+// Migrate applies migrations that are newer than the document version. Documents that are newer than current
+// version are refused.
+func Migrate(doc Doc, migrations []Migration, current int) ([]Change, error) {
+	version, err := docVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > current {
+		return nil, fmt.Errorf("%w: document has version %d, but this toolset supports versions up to %d. Upgrade toolset", ErrUnsupportedVersion, version, current)
+	}
+
+	var res []Change
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+
+		msgs, err := m.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("migrate to v%d: %w", m.Version, err)
+		}
+
+		for _, msg := range msgs {
+			res = append(res, Change{Version: m.Version, Message: msg})
+		}
+
+		doc["version"] = m.Version
+		res = append(res, Change{Version: m.Version, Message: fmt.Sprintf("set format version to %d", m.Version)})
+	}
+
+	return res, nil
+}
+
+func docVersion(doc Doc) (int, error) {
+	switch val := doc["version"].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if val != float64(int(val)) || val < 0 {
+			return 0, fmt.Errorf("invalid format version (%v)", val)
+		}
+
+		return int(val), nil
+	case int:
+		return val, nil
+	default:
+		return 0, fmt.Errorf("invalid format version (%v)", val)
+	}
+}
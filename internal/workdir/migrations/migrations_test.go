@@ -0,0 +1,123 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/kazhuravlev/optional"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	f := func(name string, migrations []migrations.Migration, current int) {
+		t.Run(name, func(t *testing.T) {
+			require.NotEmpty(t, migrations)
+			for i, m := range migrations {
+				require.Equal(t, i+1, m.Version, "migrations should be ordered and have no gaps")
+				require.NotNil(t, m.Apply)
+			}
+
+			require.Equal(t, current, migrations[len(migrations)-1].Version, "the last migration should match the current version")
+		})
+	}
+
+	f("spec", migrations.SpecMigrations(), structs.SpecVersion)
+	f("lock", migrations.LockMigrations(), structs.LockVersion)
+}
+
+func TestSpec(t *testing.T) {
+	t.Run("v0", func(t *testing.T) {
+		spec, changes, err := migrations.Spec([]byte(`{
+	"dir": "./bin/tools",
+	"tools": [{"runtime": "go", "module": "mvdan.cc/gofumpt@v0.7.0", "alias": null, "tags": []}],
+	"includes": ["/path/to/.toolset.json", {"src": "gh:owner/repo/.toolset.json", "tags": ["ci"]}]
+}`))
+		require.NoError(t, err)
+		require.Equal(t, &structs.Spec{
+			Version: 1,
+			Tools:   structs.Tools{{Runtime: "go", Module: "mvdan.cc/gofumpt@v0.7.0", Alias: optional.Empty[string](), Tags: []string{}}},
+			Includes: []structs.Include{
+				{Src: "/path/to/.toolset.json", Tags: []string{}},
+				{Src: "gh:owner/repo/.toolset.json", Tags: []string{"ci"}},
+			},
+		}, spec)
+		require.Equal(t, []migrations.Change{
+			{Version: 1, Message: "remove deprecated `dir` field. All tools are stored in TOOLSET_CACHE_DIR"},
+			{Version: 1, Message: "convert include (/path/to/.toolset.json) into object"},
+			{Version: 1, Message: "set format version to 1"},
+		}, changes)
+	})
+
+	t.Run("current", func(t *testing.T) {
+		spec, changes, err := migrations.Spec([]byte(`{"version": 1, "tools": [], "includes": []}`))
+		require.NoError(t, err)
+		require.Equal(t, structs.SpecVersion, spec.Version)
+		require.Empty(t, changes)
+	})
+
+	t.Run("newer", func(t *testing.T) {
+		_, _, err := migrations.Spec([]byte(`{"version": 100, "tools": [], "includes": []}`))
+		require.ErrorIs(t, err, migrations.ErrUnsupportedVersion)
+	})
+
+	t.Run("invalid_version", func(t *testing.T) {
+		_, _, err := migrations.Spec([]byte(`{"version": "1"}`))
+		require.Error(t, err)
+	})
+}
+
+func TestLock(t *testing.T) {
+	lock, changes, err := migrations.Lock([]byte(`{
+	"tools": [],
+	"remotes": [
+		{"Source": "/path/to/.toolset.json", "Spec": {"dir": "", "tools": [], "includes": ["/other.json"]}, "Tags": ["ci"]}
+	]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, &structs.Lock{
+		Version: 1,
+		Tools:   structs.Tools{},
+		Remotes: []structs.RemoteSpec{{
+			Source: "/path/to/.toolset.json",
+			Spec: structs.Spec{
+				Version:  1,
+				Tools:    structs.Tools{},
+				Includes: []structs.Include{{Src: "/other.json", Tags: []string{}}},
+			},
+			Tags: []string{"ci"},
+		}},
+	}, lock)
+	require.Equal(t, []migrations.Change{
+		{Version: 1, Message: "remote (/path/to/.toolset.json): rename capitalized keys"},
+		{Version: 1, Message: "set format version to 1"},
+		{Version: 1, Message: "remote (/path/to/.toolset.json): convert include (/other.json) into object"},
+		{Version: 1, Message: "remote (/path/to/.toolset.json): set format version to 1"},
+	}, changes)
+
+	t.Run("current_lock_with_old_remote_spec", func(t *testing.T) {
+		lock, changes, err := migrations.Lock([]byte(`{
+	"version": 1,
+	"tools": [],
+	"remotes": [
+		{"source": "/path/to/.toolset.json", "spec": {"tools": [], "includes": ["/other.json"]}, "tags": []}
+	]
+}`))
+		require.NoError(t, err)
+		require.Equal(t, structs.SpecVersion, lock.Remotes[0].Spec.Version)
+		require.Equal(t, []structs.Include{{Src: "/other.json", Tags: []string{}}}, lock.Remotes[0].Spec.Includes)
+		require.Equal(t, []migrations.Change{
+			{Version: 1, Message: "remote (/path/to/.toolset.json): convert include (/other.json) into object"},
+			{Version: 1, Message: "remote (/path/to/.toolset.json): set format version to 1"},
+		}, changes)
+	})
+
+	t.Run("remote_spec_from_newer_toolset", func(t *testing.T) {
+		_, _, err := migrations.Lock([]byte(`{
+	"version": 1,
+	"tools": [],
+	"remotes": [{"source": "/a.json", "spec": {"version": 100, "tools": [], "includes": []}, "tags": []}]
+}`))
+		require.ErrorIs(t, err, migrations.ErrUnsupportedVersion)
+	})
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"fmt"
+)
+
+// SpecMigrations returns ordered spec migrations.
+func SpecMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Apply: specV1},
+	}
+}
+
+// LockMigrations returns ordered lock migrations.
+func LockMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Apply: lockV1},
+	}
+}
+
+// specV1 removes deprecated `dir` field and converts string includes into objects.
+func specV1(doc Doc) ([]string, error) {
+	var res []string
+	if dir, ok := doc["dir"]; ok {
+		delete(doc, "dir")
+
+		if dir != "" {
+			res = append(res, "remove deprecated `dir` field. All tools are stored in TOOLSET_CACHE_DIR")
+		}
+	}
+
+	includes, _ := doc["includes"].([]any)
+	for i, inc := range includes {
+		src, ok := inc.(string)
+		if !ok {
+			continue
+		}
+
+		includes[i] = Doc{"src": src, "tags": []any{}}
+		res = append(res, fmt.Sprintf("convert include (%s) into object", src))
+	}
+
+	return res, nil
+}
+
+// lockV1 renames capitalized keys of remotes. Specs of remotes are migrated by Lock.
+func lockV1(doc Doc) ([]string, error) {
+	var res []string
+
+	remotes, _ := doc["remotes"].([]any)
+	for _, remote := range remotes {
+		remote, ok := remote.(Doc)
+		if !ok {
+			continue
+		}
+
+		renamed := false
+		for oldKey, newKey := range map[string]string{"Source": "source", "Spec": "spec", "Tags": "tags"} {
+			if val, ok := remote[oldKey]; ok {
+				delete(remote, oldKey)
+				remote[newKey] = val
+				renamed = true
+			}
+		}
+
+		if renamed {
+			res = append(res, fmt.Sprintf("remote (%v): rename capitalized keys", remote["source"]))
+		}
+	}
+
+	return res, nil
+}
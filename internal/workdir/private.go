@@ -1,19 +1,32 @@
 package workdir
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/timeh"
+	"github.com/kazhuravlev/toolset/internal/workdir/remotes"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
 )
 
 const (
-	EnvCacheDir = "TOOLSET_CACHE_DIR"
-	EnvSpecDir  = "TOOLSET_SPEC_DIR"
+	EnvCacheDir      = "TOOLSET_CACHE_DIR"
+	EnvSpecDir       = "TOOLSET_SPEC_DIR"
+	EnvMinReleaseAge = "TOOLSET_MIN_RELEASE_AGE"
+	EnvOffline       = "TOOLSET_OFFLINE"
+	EnvIncludeTTL    = "TOOLSET_INCLUDE_TTL"
+	EnvProfile       = "TOOLSET_PROFILE"
 )
 
+// currentPlatform returns os/arch of the current platform.
+func currentPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
 func getCacheDir(fs fsh.FS) (string, error) {
 	return getDirFromEnv(fs, EnvCacheDir, defaultCacheDir)
 }
@@ -26,6 +39,44 @@ func getSpecDir() string {
 	return defaultSpecDir
 }
 
+// getMinReleaseAge returns a min release age from profile, spec or env. Profile has the highest priority.
+func getMinReleaseAge(spec *structs.Spec, profile structs.Profile) (time.Duration, error) {
+	val := profile.MinReleaseAge
+	if val == "" {
+		val = spec.MinReleaseAge
+	}
+
+	if val == "" {
+		val = os.Getenv(EnvMinReleaseAge)
+	}
+
+	if val == "" {
+		return 0, nil
+	}
+
+	minAge, err := timeh.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("parse min release age (%s): %w", val, err)
+	}
+
+	return minAge, nil
+}
+
+// getIncludeTTL returns how long cached includes are used without revalidation.
+func getIncludeTTL() (time.Duration, error) {
+	val := os.Getenv(EnvIncludeTTL)
+	if val == "" {
+		return remotes.DefaultTTL, nil
+	}
+
+	ttl, err := timeh.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("parse include ttl (%s): %w", val, err)
+	}
+
+	return ttl, nil
+}
+
 func getDirFromEnv(fs fsh.FS, envName, defaultDir string) (string, error) {
 	dir := defaultDir
 	if specDirEnv := os.Getenv(envName); specDirEnv != "" {
@@ -49,12 +100,18 @@ func getDirFromEnv(fs fsh.FS, envName, defaultDir string) (string, error) {
 type Locations struct {
 	ToolsetFile     string
 	ToolsetLockFile string
-	CacheDir        string
-	ProjectRootDir  string
-	CurrentDir      string
-	StatsFile       string
+	// LocalFile is an optional untracked spec that is merged on top of ToolsetFile.
+	LocalFile     string
+	LocalLockFile string
+	// ShimsDir contains shims of project tools.
+	ShimsDir       string
+	CacheDir       string
+	ProjectRootDir string
+	CurrentDir     string
+	StatsFile      string
 }
 
+// getLocations resolves locations of the project. It only reads fs, the cache dir is created by caller.
 func getLocations(fs fsh.FS, currentDir string, discovery bool) (*Locations, error) {
 	currentDir, err := fsh.Abs(fs, currentDir)
 	if err != nil {
@@ -66,39 +123,80 @@ func getLocations(fs fsh.FS, currentDir string, discovery bool) (*Locations, err
 		return nil, fmt.Errorf("resolve cache dir: %w", err)
 	}
 
-	if err := fs.MkdirAll(cacheDir, fsh.DefaultDirPerm); err != nil {
-		return nil, fmt.Errorf("create cache dir: %w", err)
-	}
-
 	specDir := getSpecDir()
 
-	pathToSpec := filepath.Join(specDir, specFilename)
 	dir := currentDir
-	toolsetFilename := filepath.Join(dir, pathToSpec)
+	toolsetFilename := filepath.Join(dir, specDir, specFilename)
 	if discovery {
 		// Check that file is exists in current or parent directories.
 		for {
-			if !fsh.IsExists(fs, toolsetFilename) {
-				dir = filepath.Dir(dir)
-				if filepath.Dir(dir) == dir {
-					return nil, errors.New("unable to find spec in fs tree")
-				}
+			if filename, ok := findSpecFile(fs, filepath.Join(dir, specDir)); ok {
+				toolsetFilename = filename
 
-				toolsetFilename = filepath.Join(dir, pathToSpec)
-
-				continue
+				break
 			}
 
-			break
+			dir = filepath.Dir(dir)
+			if filepath.Dir(dir) == dir {
+				return nil, ErrSpecNotFound
+			}
 		}
 	}
 
+	localFilename, ok := findFile(fs, filepath.Dir(toolsetFilename), localFilenames())
+	if !ok {
+		localFilename = filepath.Join(filepath.Dir(toolsetFilename), localBasename+".json")
+	}
+
 	return &Locations{
 		ToolsetFile:     toolsetFilename,
 		ToolsetLockFile: filepath.Join(filepath.Dir(toolsetFilename), lockFilename),
+		LocalFile:       localFilename,
+		LocalLockFile:   filepath.Join(filepath.Dir(toolsetFilename), localLockFilename),
+		ShimsDir:        filepath.Join(dir, shimsDir),
 		CacheDir:        cacheDir,
 		StatsFile:       filepath.Join(cacheDir, statsFilename),
 		ProjectRootDir:  dir,
 		CurrentDir:      currentDir,
 	}, nil
 }
+
+// specFilenames returns supported spec filenames in order of priority.
+func specFilenames() []string {
+	return formatFilenames(specBasename)
+}
+
+// localFilenames returns supported local spec filenames in order of priority.
+func localFilenames() []string {
+	return formatFilenames(localBasename)
+}
+
+func formatFilenames(basename string) []string {
+	return []string{basename + ".json", basename + ".jsonc", basename + ".yaml", basename + ".yml"}
+}
+
+// findSpecFile returns the first spec file that exists in dir.
+func findSpecFile(fs fsh.FS, dir string) (string, bool) {
+	return findFile(fs, dir, specFilenames())
+}
+
+// findFile returns the first file of names that exists in dir.
+func findFile(fs fsh.FS, dir string, names []string) (string, bool) {
+	for _, name := range names {
+		filename := filepath.Join(dir, name)
+		if fsh.IsExists(fs, filename) {
+			return filename, true
+		}
+	}
+
+	return "", false
+}
+
+// shortCommit returns a short form of commit SHA.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+
+	return commit
+}
@@ -0,0 +1,159 @@
+package remotes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/kazhuravlev/toolset/internal/ghclient"
+	"github.com/spf13/afero"
+)
+
+const (
+	// EnvAuthTokenPrefix is a prefix of env with bearer token for host. Example: TOOLSET_AUTH_TOKEN_GITLAB_EXAMPLE_COM.
+	EnvAuthTokenPrefix = "TOOLSET_AUTH_TOKEN_"
+	// EnvAuthHeaderPrefix is a prefix of env with header template for host. Example:
+	// TOOLSET_AUTH_HEADER_GITLAB_EXAMPLE_COM='PRIVATE-TOKEN: ${GITLAB_TOKEN}'.
+	EnvAuthHeaderPrefix = "TOOLSET_AUTH_HEADER_"
+	EnvNetrc            = "NETRC"
+)
+
+// authorize adds credentials for request host. Sources are checked in order: header template env, bearer token env,
+// GitHub token (for GitHub hosts), .netrc file.
+func (f *Fetcher) authorize(req *http.Request) error {
+	host := req.URL.Hostname()
+	envKey := hostEnvKey(host)
+
+	if tpl := os.Getenv(EnvAuthHeaderPrefix + envKey); tpl != "" {
+		name, value, ok := strings.Cut(os.ExpandEnv(tpl), ":")
+		if !ok {
+			return fmt.Errorf("invalid header template in %s: should be `Name: value`", EnvAuthHeaderPrefix+envKey)
+		}
+
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		return nil
+	}
+
+	if token := os.Getenv(EnvAuthTokenPrefix + envKey); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	if token := ghclient.Token(); token != "" && ghclient.IsGithubHost(host) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	login, password, ok, err := f.netrcCredentials(host)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		req.SetBasicAuth(login, password)
+	}
+
+	return nil
+}
+
+// hostEnvKey converts host to env suffix: gitlab.example.com => GITLAB_EXAMPLE_COM.
+func hostEnvKey(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+
+		return '_'
+	}, host)
+}
+
+// netrcCredentials returns credentials for host from $NETRC or ~/.netrc file.
+func (f *Fetcher) netrcCredentials(host string) (string, string, bool, error) {
+	filename := os.Getenv(EnvNetrc)
+	if filename == "" {
+		homeDir, err := f.fs.GetHomeDir()
+		if err != nil {
+			return "", "", false, fmt.Errorf("get home dir: %w", err)
+		}
+
+		filename = filepath.Join(homeDir, ".netrc")
+	}
+
+	bb, err := afero.ReadFile(f.fs, filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", false, nil
+		}
+
+		return "", "", false, fmt.Errorf("read netrc: %w", err)
+	}
+
+	login, password, ok := parseNetrc(bb, host)
+
+	return login, password, ok, nil
+}
+
+// parseNetrc finds credentials for host. Entry `default` is used when host is not found.
+func parseNetrc(bb []byte, host string) (string, string, bool) {
+	type entry struct {
+		login, password string
+	}
+
+	var (
+		found, fallback *entry
+		cur             *entry
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(bb))
+	scanner.Split(bufio.ScanWords)
+
+	next := func() string {
+		if scanner.Scan() {
+			return scanner.Text()
+		}
+
+		return ""
+	}
+
+loop:
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			cur = &entry{}
+			if next() == host && found == nil {
+				found = cur
+			}
+		case "default":
+			cur = &entry{}
+			if fallback == nil {
+				fallback = cur
+			}
+		case "login":
+			if val := next(); cur != nil {
+				cur.login = val
+			}
+		case "password":
+			if val := next(); cur != nil {
+				cur.password = val
+			}
+		case "macdef":
+			// NOTE: macros are not supported. Stop here to not parse macro body.
+			break loop
+		}
+	}
+
+	if found == nil {
+		found = fallback
+	}
+
+	if found == nil {
+		return "", "", false
+	}
+
+	return found.login, found.password, true
+}
@@ -0,0 +1,74 @@
+package remotes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `
+machine example.com
+  login user1
+  password pass1
+
+machine other.com login user2 password pass2
+
+default login anonymous password secret
+
+macdef init
+machine macro.com login macro password macro
+`
+
+	f := func(host, expLogin, expPassword string, expOK bool) {
+		t.Run(host, func(t *testing.T) {
+			login, password, ok := parseNetrc([]byte(netrc), host)
+			require.Equal(t, expOK, ok)
+			require.Equal(t, expLogin, login)
+			require.Equal(t, expPassword, password)
+		})
+	}
+
+	f("example.com", "user1", "pass1", true)
+	f("other.com", "user2", "pass2", true)
+	f("unknown.com", "anonymous", "secret", true)
+	f("macro.com", "anonymous", "secret", true)
+
+	_, _, ok := parseNetrc([]byte(`machine example.com login user password pass`), "unknown.com")
+	require.False(t, ok)
+}
+
+func TestHostEnvKey(t *testing.T) {
+	require.Equal(t, "GITLAB_EXAMPLE_COM", hostEnvKey("gitlab.example.com"))
+	require.Equal(t, "MY_HOST_8080", hostEnvKey("my-host:8080"))
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Setenv(EnvNetrc, "/home/.netrc")
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("TOOLSET_GITHUB_TOKEN", "")
+	t.Setenv(EnvAuthTokenPrefix+"TOKEN_EXAMPLE_COM", "bearer-token")
+	t.Setenv("MY_SECRET", "secret-value")
+	t.Setenv(EnvAuthHeaderPrefix+"HEADER_EXAMPLE_COM", "PRIVATE-TOKEN: ${MY_SECRET}")
+
+	fetcher := NewFetcher(fsh.NewMemFS(map[string]string{
+		"/home/.netrc": `machine netrc.example.com login user password pass`,
+	}), "/cache")
+
+	f := func(url string, expHeaders http.Header) {
+		t.Run(url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			require.NoError(t, fetcher.authorize(req))
+			require.Equal(t, expHeaders, req.Header)
+		})
+	}
+
+	f("https://header.example.com/spec.json", http.Header{"Private-Token": {"secret-value"}})
+	f("https://token.example.com/spec.json", http.Header{"Authorization": {"Bearer bearer-token"}})
+	f("https://raw.githubusercontent.com/owner/repo/main/spec.json", http.Header{"Authorization": {"Bearer gh-token"}})
+	f("https://netrc.example.com/spec.json", http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}})
+	f("https://public.example.com/spec.json", http.Header{})
+}
@@ -0,0 +1,531 @@
+package remotes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/ghclient"
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
+)
+
+const (
+	DefaultTTL = time.Hour
+
+	maxAttempts       = 3
+	defaultRetryDelay = 500 * time.Millisecond
+
+	// cacheDirName is a directory inside toolset cache dir.
+	cacheDirName = ".includes"
+)
+
+var ErrNotCached = errors.New("source is not cached")
+
+var reCommit = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Fetcher fetches remote specs. All fetched sources are cached, so they can be used offline.
+type Fetcher struct {
+	fs       fsh.FS
+	cacheDir string
+	client   *http.Client
+	ttl      time.Duration
+	offline  bool
+	dryRun   bool
+
+	retryDelay time.Duration
+	// github is created on demand.
+	github *github.Client
+}
+
+func NewFetcher(fs fsh.FS, cacheDir string) *Fetcher {
+	return &Fetcher{
+		fs:       fs,
+		cacheDir: filepath.Join(cacheDir, cacheDirName),
+		client:   http.DefaultClient,
+		ttl:      DefaultTTL,
+		offline:  false,
+
+		retryDelay: defaultRetryDelay,
+	}
+}
+
+// SetOffline enables offline mode. In this mode all sources are served from cache.
+func (f *Fetcher) SetOffline(enabled bool) {
+	f.offline = enabled
+}
+
+// SetDryRun makes git sources to be fetched into a temporary mirror, so the cache dir is not touched by git.
+func (f *Fetcher) SetDryRun(enabled bool) {
+	f.dryRun = enabled
+}
+
+// SetTTL sets how long cached sources are used without revalidation.
+func (f *Fetcher) SetTTL(ttl time.Duration) {
+	f.ttl = ttl
+}
+
+type FetchOpts struct {
+	// Pinned contains commits (by source) that should be used for git sources instead of a ref from source uri.
+	Pinned map[string]string
+	// Revalidate ignores TTL and checks that source was not changed.
+	Revalidate bool
+}
+
+// FetchRemote fetches the source spec and all of its includes.
+func (f *Fetcher) FetchRemote(ctx context.Context, source string, tags []string, opts FetchOpts) ([]structs.RemoteSpec, error) {
+	return f.fetchRemote(ctx, source, tags, opts, nil, nil)
+}
+
+// fetchRemote fetches the source. Via is a chain of sources that leads to this one.
+func (f *Fetcher) fetchRemote(ctx context.Context, source string, tags []string, opts FetchOpts, via, excluded []string) ([]structs.RemoteSpec, error) {
+	{
+		if slices.Contains(excluded, source) {
+			return []structs.RemoteSpec{}, nil
+		}
+
+		excluded = append(excluded, source)
+	}
+
+	srcURI, err := ParseRemote(source)
+	if err != nil {
+		return nil, fmt.Errorf("parse source uri: %w", err)
+	}
+
+	buf, commit, err := f.fetchSource(ctx, source, srcURI, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	specJSON, err := specfile.ToJSON(specfile.FormatAuto, buf)
+	if err != nil {
+		return nil, fmt.Errorf("parse source: %w", err)
+	}
+
+	spec, _, err := migrations.Spec(specJSON)
+	if err != nil {
+		return nil, fmt.Errorf("parse source: %w", err)
+	}
+
+	var res []structs.RemoteSpec
+	for _, inc := range spec.Includes {
+		remotes, err := f.fetchRemote(ctx, inc.Src, append(slices.Clone(tags), inc.Tags...), opts, append(slices.Clone(via), source), excluded)
+		if err != nil {
+			return nil, fmt.Errorf("fetch one of remotes (%s): %w", inc.Src, err)
+		}
+
+		for _, remote := range remotes {
+			excluded = append(excluded, remote.Source)
+		}
+
+		res = append(res, remotes...)
+	}
+
+	return append(res, structs.RemoteSpec{
+		Spec:   *spec,
+		Source: source,
+		Tags:   tags,
+		Commit: commit,
+		Hash:   Hash(buf),
+		Via:    via,
+	}), nil
+}
+
+// fetchSource returns content of source and resolved commit (for git sources).
+func (f *Fetcher) fetchSource(ctx context.Context, source string, srcURI SourceUri, opts FetchOpts) ([]byte, string, error) {
+	switch srcURI := srcURI.(type) {
+	default:
+		return nil, "", errors.New("unsupported source uri")
+	case SourceUriFile:
+		fmt.Println("Include from file:", srcURI.Path)
+
+		bb, err := afero.ReadFile(f.fs, srcURI.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("read file: %w", err)
+		}
+
+		return bb, "", nil
+	case SourceUriUrl:
+		fmt.Println("Include from url:", srcURI.URL)
+
+		entry, err := f.cached(ctx, source, "", opts.Revalidate, func(cached *cacheEntry) (*cacheEntry, error) {
+			return f.fetchHTTP(ctx, srcURI.URL, cached)
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		return entry.Content, "", nil
+	case SourceUriGit:
+		ref := srcURI.Ref
+		if commit, ok := opts.Pinned[source]; ok {
+			ref = commit
+		}
+
+		fmt.Println("Include from git:", srcURI.Addr, "file:", srcURI.Path, "ref:", ref)
+
+		// NOTE: content of commit is never changed.
+		revalidate := opts.Revalidate && !reCommit.MatchString(ref)
+		entry, err := f.cached(ctx, source, ref, revalidate, func(*cacheEntry) (*cacheEntry, error) {
+			bb, commit, err := f.fetchGit(ctx, srcURI.Addr, ref, srcURI.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			return &cacheEntry{Content: bb, Commit: commit}, nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		return entry.Content, entry.Commit, nil
+	case SourceUriGithub:
+		ref := srcURI.Ref
+		if commit, ok := opts.Pinned[source]; ok {
+			ref = commit
+		}
+
+		fmt.Println("Include from github:", srcURI.Owner+"/"+srcURI.Repo, "file:", srcURI.Path, "ref:", ref)
+
+		revalidate := opts.Revalidate && !reCommit.MatchString(ref)
+		entry, err := f.cached(ctx, source, ref, revalidate, func(*cacheEntry) (*cacheEntry, error) {
+			bb, commit, err := f.fetchGithub(ctx, srcURI.Owner, srcURI.Repo, ref, srcURI.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			return &cacheEntry{Content: bb, Commit: commit}, nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		return entry.Content, entry.Commit, nil
+	}
+}
+
+type cacheEntry struct {
+	Source       string    `json:"source"`
+	Ref          string    `json:"ref,omitempty"`
+	Commit       string    `json:"commit,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	Content      []byte    `json:"content"`
+}
+
+// cached returns a cached source when it is fresh enough. Otherwise, it calls fetch and stores the result. Stale cache
+// is used only when source can not be fetched because of network problems. Errors like 404 or 401 are returned, even
+// when there is a cached source, because the source was removed or access was revoked.
+func (f *Fetcher) cached(ctx context.Context, source, ref string, revalidate bool, fetch func(cached *cacheEntry) (*cacheEntry, error)) (*cacheEntry, error) {
+	filename := filepath.Join(f.cacheDir, cacheKey(source, ref)+".json")
+
+	var cached *cacheEntry
+	if fsh.IsExists(f.fs, filename) {
+		entry, err := fsh.ReadJson[cacheEntry](ctx, f.fs, filename)
+		if err != nil {
+			return nil, fmt.Errorf("read cached source: %w", err)
+		}
+
+		cached = entry
+	}
+
+	switch {
+	case f.offline && cached == nil:
+		return nil, fmt.Errorf("offline mode (%s): %w", source, ErrNotCached)
+	case f.offline:
+		return cached, nil
+	case cached != nil && !revalidate && time.Since(cached.FetchedAt) < f.ttl:
+		return cached, nil
+	}
+
+	entry, err := fetch(cached)
+	if err != nil {
+		if cached == nil || !isTemporary(err) {
+			return nil, err
+		}
+
+		fmt.Println("Warning: use cached source:", err)
+
+		return cached, nil
+	}
+
+	entry.Source = source
+	entry.Ref = ref
+	entry.FetchedAt = time.Now()
+
+	if err := f.fs.MkdirAll(f.cacheDir, fsh.DefaultDirPerm); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	if err := fsh.WriteJson(ctx, f.fs, entry, filename); err != nil {
+		return nil, fmt.Errorf("write cached source: %w", err)
+	}
+
+	return entry, nil
+}
+
+// HTTPError is returned when server responds with unexpected status.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e HTTPError) Error() string {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return fmt.Sprintf("fetch %s: %s (check that url is correct and credentials are configured)", e.URL, e.Status)
+	default:
+		return fmt.Sprintf("fetch %s: %s", e.URL, e.Status)
+	}
+}
+
+// gitNetworkErrors are parts of git messages about network problems.
+var gitNetworkErrors = []string{
+	"Could not resolve host",
+	"Could not resolve hostname",
+	"Connection refused",
+	"Connection timed out",
+	"Operation timed out",
+	"Network is unreachable",
+	"Failed to connect",
+}
+
+// isTemporary returns true for network problems and server errors. Stale cache can be used in this case.
+func isTemporary(err error) bool {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		code := ghErr.Response.StatusCode
+
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+
+	var rateErr *github.RateLimitError
+	var netErr net.Error
+	if errors.As(err, &rateErr) || errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, part := range gitNetworkErrors {
+		if strings.Contains(msg, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchHTTP downloads the url. Cached entry is used to revalidate a content by ETag and Last-Modified headers.
+// Network errors and server errors are retried.
+func (f *Fetcher) fetchHTTP(ctx context.Context, url string, cached *cacheEntry) (*cacheEntry, error) {
+	var lastErr error
+	for attempt := range maxAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(f.retryDelay * time.Duration(attempt)):
+			}
+		}
+
+		entry, retry, err := f.fetchHTTPOnce(ctx, url, cached)
+		if err == nil {
+			return entry, nil
+		}
+
+		if !retry {
+			return nil, err
+		}
+
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("fetch source after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// fetchHTTPOnce makes one request. Returns true when request can be retried.
+func (f *Fetcher) fetchHTTPOnce(ctx context.Context, url string, cached *cacheEntry) (*cacheEntry, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("create request: %w", err)
+	}
+
+	if err := f.authorize(req); err != nil {
+		return nil, false, fmt.Errorf("authorize request: %w", err)
+	}
+
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, fmt.Errorf("fetch source: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, false, nil
+	case resp.StatusCode != http.StatusOK:
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+
+		return nil, retry, HTTPError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	bb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("read response body: %w", err)
+	}
+
+	return &cacheEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Content:      bb,
+	}, false, nil
+}
+
+// fetchGithub reads a file from GitHub repository by contents API. Empty ref means the default branch. Returns file
+// content and resolved commit SHA.
+func (f *Fetcher) fetchGithub(ctx context.Context, owner, repo, ref, path string) ([]byte, string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	client := f.githubClient(ctx)
+
+	commit, _, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve commit (%s/%s@%s): %w", owner, repo, ref, err)
+	}
+
+	file, _, _, err := client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: commit})
+	if err != nil {
+		return nil, "", fmt.Errorf("get file (%s/%s/%s): %w", owner, repo, path, err)
+	}
+
+	if file == nil {
+		return nil, "", fmt.Errorf("path (%s) is not a file", path)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, "", fmt.Errorf("decode file content: %w", err)
+	}
+
+	return []byte(content), commit, nil
+}
+
+func (f *Fetcher) githubClient(ctx context.Context) *github.Client {
+	if f.github == nil {
+		f.github = ghclient.New(ctx)
+	}
+
+	return f.github
+}
+
+// fetchGit reads a file from git repository at the given ref. Empty ref means the default branch. Repository is
+// fetched into a bare mirror that is reused between calls. Returns file content and resolved commit SHA.
+func (f *Fetcher) fetchGit(ctx context.Context, addr, ref, path string) ([]byte, string, error) {
+	mirror := filepath.Join(f.cacheDir, "git", cacheKey(addr, ""))
+	if f.dryRun {
+		// NOTE: git works with the disk directly, so a temp dir is used instead of the cached mirror.
+		dir, err := afero.TempDir(f.fs, "", "toolset-git-")
+		if err != nil {
+			return nil, "", fmt.Errorf("create temp git mirror: %w", err)
+		}
+		defer f.fs.RemoveAll(dir) //nolint:errcheck
+
+		mirror = filepath.Join(dir, "mirror")
+	}
+
+	if !fsh.IsExists(f.fs, mirror) {
+		if _, err := runGit(ctx, "init", "--quiet", "--bare", mirror); err != nil {
+			return nil, "", fmt.Errorf("init git mirror: %w", err)
+		}
+	}
+
+	rev := ref
+	if !reCommit.MatchString(ref) || !hasCommit(ctx, mirror, ref) {
+		fetchRef := ref
+		if fetchRef == "" {
+			fetchRef = "HEAD"
+		}
+
+		if _, err := runGit(ctx, "--git-dir", mirror, "fetch", "--quiet", addr, fetchRef); err != nil {
+			return nil, "", fmt.Errorf("fetch git repo: %w", err)
+		}
+
+		// NOTE: FETCH_HEAD of annotated tag is a tag object, so it is peeled to the commit.
+		out, err := runGit(ctx, "--git-dir", mirror, "rev-parse", "FETCH_HEAD^{commit}")
+		if err != nil {
+			return nil, "", fmt.Errorf("resolve commit: %w", err)
+		}
+
+		rev = strings.TrimSpace(string(out))
+	}
+
+	bb, err := runGit(ctx, "--git-dir", mirror, "show", rev+":"+strings.TrimPrefix(filepath.ToSlash(path), "/"))
+	if err != nil {
+		return nil, "", fmt.Errorf("read file: %w", err)
+	}
+
+	return bb, rev, nil
+}
+
+func hasCommit(ctx context.Context, mirror, commit string) bool {
+	_, err := runGit(ctx, "--git-dir", mirror, "cat-file", "-e", commit+"^{commit}")
+
+	return err == nil
+}
+
+func runGit(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdin = nil
+	cmdOut := bytes.NewBuffer(nil)
+	cmd.Stdout = cmdOut
+	cmdErr := bytes.NewBufferString("")
+	cmd.Stderr = cmdErr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s (%s): %w", strings.Join(args, " "), strings.TrimSpace(cmdErr.String()), err)
+	}
+
+	return cmdOut.Bytes(), nil
+}
+
+func cacheKey(source, ref string) string {
+	sum := sha256.Sum256([]byte(source + "#" + ref))
+
+	return hex.EncodeToString(sum[:16])
+}
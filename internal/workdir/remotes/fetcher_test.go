@@ -0,0 +1,281 @@
+package remotes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcherGit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	repo := t.TempDir()
+
+	git := func(args ...string) string {
+		out, err := runGit(ctx, append([]string{"-C", repo}, args...)...)
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(content string) string {
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "spec.json"), []byte(content), 0o644))
+		git("add", "spec.json")
+		git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content)
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "--quiet", "--initial-branch=main")
+	commit1 := commit(`{"tools":[]}`)
+	git("tag", "v1.0.0")
+	commit2 := commit(`{"tools":[],"includes":[]}`)
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "tag", "-a", "v2.0.0", "-m", "release")
+
+	fetcher := NewFetcher(fsh.NewRealFS(), t.TempDir())
+
+	f := func(ref, expContent, expCommit string) {
+		t.Run("ref_"+ref, func(t *testing.T) {
+			bb, rev, err := fetcher.fetchGit(ctx, "file://"+repo, ref, "/spec.json")
+			require.NoError(t, err)
+			require.Equal(t, expContent, string(bb))
+			require.Equal(t, expCommit, rev)
+		})
+	}
+
+	f("", `{"tools":[],"includes":[]}`, commit2)
+	f("main", `{"tools":[],"includes":[]}`, commit2)
+	f("v1.0.0", `{"tools":[]}`, commit1)
+	f("v2.0.0", `{"tools":[],"includes":[]}`, commit2)
+	f(commit1, `{"tools":[]}`, commit1)
+
+	t.Run("dry_run", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		fetcher := NewFetcher(fsh.NewOverlayFS(fsh.NewRealFS()), cacheDir)
+		fetcher.SetDryRun(true)
+
+		bb, rev, err := fetcher.fetchGit(ctx, "file://"+repo, "v1.0.0", "/spec.json")
+		require.NoError(t, err)
+		require.Equal(t, `{"tools":[]}`, string(bb))
+		require.Equal(t, commit1, rev)
+
+		entries, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		source := "git+file://" + repo + ":/spec.json#v1.0.0"
+		srcURI := SourceUriGit{Addr: "file://" + repo, Path: "/spec.json", Ref: "v1.0.0"}
+
+		_, _, err := fetcher.fetchSource(ctx, source, srcURI, FetchOpts{})
+		require.NoError(t, err)
+
+		// Repo is gone, but source is cached.
+		require.NoError(t, os.RemoveAll(repo))
+		fetcher.SetOffline(true)
+		defer fetcher.SetOffline(false)
+
+		bb, rev, err := fetcher.fetchSource(ctx, source, srcURI, FetchOpts{})
+		require.NoError(t, err)
+		require.Equal(t, `{"tools":[]}`, string(bb))
+		require.Equal(t, commit1, rev)
+	})
+}
+
+func TestFetcherHTTP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	const etag = `"v1"`
+
+	var requests, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(`{"tools":[]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	fetcher := NewFetcher(fsh.NewMemFS(nil), "/cache")
+
+	fetch := func(opts FetchOpts) {
+		res, err := fetcher.FetchRemote(ctx, srv.URL+"/spec.json", nil, opts)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, Hash([]byte(`{"tools":[]}`)), res[0].Hash)
+	}
+
+	fetch(FetchOpts{})
+	require.Equal(t, 1, requests)
+
+	// Fresh cache is used without requests.
+	fetch(FetchOpts{})
+	require.Equal(t, 1, requests)
+
+	// Revalidation by ETag.
+	fetch(FetchOpts{Revalidate: true})
+	require.Equal(t, 2, requests)
+	require.Equal(t, 1, notModified)
+
+	// Offline mode never makes requests.
+	fetcher.SetOffline(true)
+	fetch(FetchOpts{Revalidate: true})
+	require.Equal(t, 2, requests)
+
+	_, err := fetcher.FetchRemote(ctx, srv.URL+"/unknown.json", nil, FetchOpts{})
+	require.ErrorIs(t, err, ErrNotCached)
+}
+
+func TestFetcherHTTPErrors(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch r.URL.Path {
+		case "/flaky.json":
+			if requests == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"tools":[]}`))
+		case "/down.json":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	fetcher := NewFetcher(fsh.NewMemFS(nil), "/cache")
+	fetcher.retryDelay = 0
+
+	t.Run("retry_on_server_error", func(t *testing.T) {
+		requests = 0
+		_, err := fetcher.FetchRemote(ctx, srv.URL+"/flaky.json", nil, FetchOpts{})
+		require.NoError(t, err)
+		require.Equal(t, 2, requests)
+	})
+
+	t.Run("retries_exhausted", func(t *testing.T) {
+		requests = 0
+		_, err := fetcher.FetchRemote(ctx, srv.URL+"/down.json", nil, FetchOpts{})
+		var httpErr HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+		require.Equal(t, maxAttempts, requests)
+	})
+
+	t.Run("not_found_is_not_retried", func(t *testing.T) {
+		requests = 0
+		_, err := fetcher.FetchRemote(ctx, srv.URL+"/unknown.json", nil, FetchOpts{})
+		var httpErr HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+		require.Equal(t, 1, requests)
+	})
+}
+
+func TestFetcherStaleCache(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"tools":[]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	fetcher := NewFetcher(fsh.NewMemFS(nil), "/cache")
+	fetcher.retryDelay = 0
+
+	source := srv.URL + "/spec.json"
+	_, err := fetcher.FetchRemote(ctx, source, nil, FetchOpts{})
+	require.NoError(t, err)
+
+	t.Run("server_error", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		res, err := fetcher.FetchRemote(ctx, source, nil, FetchOpts{Revalidate: true})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		status = http.StatusNotFound
+		_, err := fetcher.FetchRemote(ctx, source, nil, FetchOpts{Revalidate: true})
+		var httpErr HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		status = http.StatusUnauthorized
+		_, err := fetcher.FetchRemote(ctx, source, nil, FetchOpts{Revalidate: true})
+		var httpErr HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	})
+
+	t.Run("network_error", func(t *testing.T) {
+		srv.Close()
+		res, err := fetcher.FetchRemote(ctx, source, nil, FetchOpts{Revalidate: true})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+	})
+}
+
+func TestFetcherGithub(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/commits/v1.0.0":
+			_, _ = w.Write([]byte(commit))
+		case "/repos/owner/repo/contents/specs/.toolset.json":
+			require.Equal(t, commit, r.URL.Query().Get("ref"))
+			_, _ = w.Write([]byte(`{"type":"file","encoding":"base64","content":"eyJ0b29scyI6W119"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	fetcher := NewFetcher(fsh.NewMemFS(nil), "/cache")
+	fetcher.github = github.NewClient(srv.Client())
+	fetcher.github.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	res, err := fetcher.FetchRemote(ctx, "gh:owner/repo/specs/.toolset.json@v1.0.0", nil, FetchOpts{})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, commit, res[0].Commit)
+	require.Equal(t, Hash([]byte(`{"tools":[]}`)), res[0].Hash)
+}
@@ -1,23 +1,12 @@
 package remotes
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"os"
-	"os/exec"
 	"path/filepath"
-	"slices"
 	"strings"
-
-	"github.com/kazhuravlev/toolset/internal/fsh"
-	"github.com/kazhuravlev/toolset/internal/workdir/structs"
-	"github.com/spf13/afero"
 )
 
 func ParseRemote(uri string) (SourceUri, error) {
@@ -29,6 +18,8 @@ func ParseRemote(uri string) (SourceUri, error) {
 	switch sourceURL.Scheme {
 	default:
 		return nil, fmt.Errorf("unsupported source uri scheme (%s)", sourceURL.Scheme)
+	case "git+ssh", "git+https":
+		return parseGit(uri)
 	case "":
 		uri, err := filepath.Abs(uri)
 		if err != nil {
@@ -38,134 +29,60 @@ func ParseRemote(uri string) (SourceUri, error) {
 		return SourceUriFile{Path: uri}, nil
 	case "http", "https":
 		return SourceUriUrl{URL: uri}, nil
-	case "git+ssh":
-		parts := strings.Split(uri, ":")
-		pathToFile := parts[len(parts)-1]
-
-		return SourceUriGit{
-			Addr: strings.TrimSuffix(strings.TrimPrefix(uri, "git+ssh://"), ":"+pathToFile),
-			Path: pathToFile,
-		}, nil
-	case "git+https":
-		parts := strings.Split(uri, ":")
-		pathToFile := parts[len(parts)-1]
-
-		return SourceUriGit{
-			Addr: strings.TrimSuffix(strings.TrimPrefix(uri, "git+"), ":"+pathToFile),
-			Path: pathToFile,
-		}, nil
+	case "gh":
+		return parseGithub(sourceURL.Opaque)
 	}
 }
 
-func FetchRemote(ctx context.Context, fs fsh.FS, source string, tags []string, excluded []string) ([]structs.RemoteSpec, error) {
-	{
-		if slices.Contains(excluded, source) {
-			return []structs.RemoteSpec{}, nil
-		}
-
-		excluded = append(excluded, source)
+// parseGithub parses github shorthand like `owner/repo/path/to/file.json@ref`. Ref is optional and can be a tag,
+// a branch or a commit SHA.
+func parseGithub(str string) (SourceUri, error) {
+	var ref string
+	if idx := strings.LastIndex(str, "@"); idx != -1 {
+		str, ref = str[:idx], str[idx+1:]
 	}
 
-	srcURI, err := ParseRemote(source)
-	if err != nil {
-		return nil, fmt.Errorf("parse source uri: %w", err)
+	parts := strings.SplitN(str, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid github source (%s): should be gh:owner/repo/path/to/file.json@ref", str)
 	}
 
-	var buf []byte
-	switch srcURI := srcURI.(type) {
-	default:
-		return nil, errors.New("unsupported source uri")
-	case SourceUriUrl:
-		fmt.Println("Include from url:", srcURI.URL)
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srcURI.URL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("create request: %w", err)
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetch source: %w", err)
-		}
-		defer resp.Body.Close() //nolint:errcheck
-
-		bb, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("read response body: %w", err)
-		}
-
-		buf = bb
-	case SourceUriFile:
-		fmt.Println("Include from file:", srcURI.Path)
-
-		bb, err := afero.ReadFile(fs, srcURI.Path)
-		if err != nil {
-			return nil, fmt.Errorf("read file: %w", err)
-		}
-
-		buf = bb
-	case SourceUriGit:
-		fmt.Println("Include from git:", srcURI.Addr, "file:", srcURI.Path)
-
-		targetDir, err := afero.TempDir(fs, "", "toolset")
-		if err != nil {
-			return nil, fmt.Errorf("create temp dir: %w", err)
-		}
-
-		args := []string{
-			"clone",
-			"--depth", "1",
-			srcURI.Addr,
-			targetDir,
-		}
-
-		cmd := exec.CommandContext(ctx, "git", args...)
-		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
-		cmd.Stdin = nil
-		cmd.Stdout = io.Discard
-		cmdErr := bytes.NewBufferString("")
-		cmd.Stderr = cmdErr
-		if err := cmd.Run(); err != nil {
-			return nil, fmt.Errorf("clone git repo (%s): %w", strings.TrimSpace(cmdErr.String()), err)
-		}
-
-		targetFile := filepath.Join(targetDir, srcURI.Path)
-		bb, err := afero.ReadFile(fs, targetFile)
-		if err != nil {
-			return nil, fmt.Errorf("read file: %w", err)
-		}
+	return SourceUriGithub{
+		Owner: parts[0],
+		Repo:  parts[1],
+		Path:  parts[2],
+		Ref:   ref,
+	}, nil
+}
 
-		if err := fs.RemoveAll(targetDir); err != nil {
-			return nil, fmt.Errorf("remove temp dir: %w", err)
-		}
+// parseGit parses git uri like `git+https://host/repo.git:/path/to/file.json#ref`. Ref is optional and can be a tag,
+// a branch or a commit SHA.
+func parseGit(uri string) (SourceUri, error) {
+	uri, ref, _ := strings.Cut(uri, "#")
 
-		buf = bb
-	}
+	parts := strings.Split(uri, ":")
+	pathToFile := parts[len(parts)-1]
 
-	var spec structs.Spec
-	if err := json.Unmarshal(buf, &spec); err != nil {
-		return nil, fmt.Errorf("parse source: %w", err)
+	var addr string
+	switch {
+	case strings.HasPrefix(uri, "git+ssh://"):
+		addr = strings.TrimPrefix(uri, "git+ssh://")
+	default:
+		addr = strings.TrimPrefix(uri, "git+")
 	}
 
-	var res []structs.RemoteSpec
-	for _, inc := range spec.Includes {
-		remotes, err := FetchRemote(ctx, fs, inc.Src, append(slices.Clone(tags), inc.Tags...), excluded)
-		if err != nil {
-			return nil, fmt.Errorf("fetch one of remotes (%s): %w", inc, err)
-		}
-
-		for _, remote := range remotes {
-			excluded = append(excluded, remote.Source)
-		}
+	return SourceUriGit{
+		Addr: strings.TrimSuffix(addr, ":"+pathToFile),
+		Path: pathToFile,
+		Ref:  ref,
+	}, nil
+}
 
-		res = append(res, remotes...)
-	}
+// Hash returns a checksum of source content.
+func Hash(bb []byte) string {
+	sum := sha256.Sum256(bb)
 
-	return append(res, structs.RemoteSpec{
-		Spec:   spec,
-		Source: source,
-		Tags:   tags,
-	}), nil
+	return "sha256:" + hex.EncodeToString(sum[:])
 }
 
 type SourceUri interface {
@@ -187,6 +104,18 @@ func (SourceUriUrl) isSourceUri() {}
 type SourceUriGit struct {
 	Addr string
 	Path string
+	// Ref is a tag, a branch or a commit SHA. Empty means the default branch.
+	Ref string
 }
 
 func (SourceUriGit) isSourceUri() {}
+
+type SourceUriGithub struct {
+	Owner string
+	Repo  string
+	Path  string
+	// Ref is a tag, a branch or a commit SHA. Empty means the default branch.
+	Ref string
+}
+
+func (SourceUriGithub) isSourceUri() {}
@@ -36,12 +36,26 @@ func TestParseRemote(t *testing.T) {
 			remotes.SourceUriGit{Addr: "127.0.0.1", Path: "/path/to/file.txt"})
 		f("git+https://127.0.0.1:/path/to/file.txt",
 			remotes.SourceUriGit{Addr: "https://127.0.0.1", Path: "/path/to/file.txt"})
+		f("git+https://127.0.0.1/repo.git:/path/to/file.txt#v1.2.0",
+			remotes.SourceUriGit{Addr: "https://127.0.0.1/repo.git", Path: "/path/to/file.txt", Ref: "v1.2.0"})
+		f("gh:owner/repo/path/to/file.json@v1.2.0",
+			remotes.SourceUriGithub{Owner: "owner", Repo: "repo", Path: "path/to/file.json", Ref: "v1.2.0"})
+		f("gh:owner/repo/.toolset.json",
+			remotes.SourceUriGithub{Owner: "owner", Repo: "repo", Path: ".toolset.json", Ref: ""})
+		f("git+ssh://127.0.0.1:/path/to/file.txt#main",
+			remotes.SourceUriGit{Addr: "127.0.0.1", Path: "/path/to/file.txt", Ref: "main"})
 	})
 
 	t.Run("invalid_cases", func(t *testing.T) {
-		res, err := remotes.ParseRemote("ftp://127.0.0.1:8000/path/to/file.txt")
-		require.Error(t, err)
-		require.Nil(t, res)
+		f := func(uri string) {
+			res, err := remotes.ParseRemote(uri)
+			require.Error(t, err)
+			require.Nil(t, res)
+		}
+
+		f("ftp://127.0.0.1:8000/path/to/file.txt")
+		f("gh:owner/repo")
+		f("gh:owner/repo/@main")
 	})
 }
 
@@ -52,8 +66,7 @@ func TestFetchRemote(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		fs := fsh.NewMemFS(map[string]string{
-			"/.toolset.json": `{
+		const content = `{
 			"dir": "./bin/tools",
 			"tools": [
 				{
@@ -69,16 +82,18 @@ func TestFetchRemote(t *testing.T) {
 					"tags": ["tag3"]
 				}
 			]
-		}`,
+		}`
+		fs := fsh.NewMemFS(map[string]string{
+			"/.toolset.json": content,
 		})
 
-		res, err := remotes.FetchRemote(ctx, fs, "/.toolset.json", []string{"tag2"}, nil)
+		res, err := remotes.NewFetcher(fs, "/cache").FetchRemote(ctx, "/.toolset.json", []string{"tag2"}, remotes.FetchOpts{})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 		require.Equal(t, structs.RemoteSpec{
 			Source: "/.toolset.json",
 			Spec: structs.Spec{
-				Dir: "./bin/tools",
+				Version: structs.SpecVersion,
 				Tools: structs.Tools{
 					{
 						Runtime: "go",
@@ -95,20 +110,47 @@ func TestFetchRemote(t *testing.T) {
 				},
 			},
 			Tags: []string{"tag2"},
+			Hash: remotes.Hash([]byte(content)),
 		}, res[0])
 	})
 
+	t.Run("nested_file_src", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("skip for Windows")
+		}
+
+		ctx := context.Background()
+		fs := fsh.NewMemFS(map[string]string{
+			"/a.json": `{"tools": [], "includes": [{"src": "/b.json"}]}`,
+			"/b.json": `{"tools": [], "includes": [{"src": "/c.json"}]}`,
+			"/c.json": `{"tools": []}`,
+		})
+
+		res, err := remotes.NewFetcher(fs, "/cache").FetchRemote(ctx, "/a.json", nil, remotes.FetchOpts{})
+		require.NoError(t, err)
+		require.Len(t, res, 3)
+		require.Equal(t, "/c.json", res[0].Source)
+		require.Equal(t, []string{"/a.json", "/b.json"}, res[0].Via)
+		require.Equal(t, "/b.json", res[1].Source)
+		require.Equal(t, []string{"/a.json"}, res[1].Via)
+		require.Equal(t, "/a.json", res[2].Source)
+		require.Empty(t, res[2].Via)
+	})
+
 	t.Run("git_https_src", func(t *testing.T) {
 		ctx := context.Background()
 		fs := fsh.NewRealFS()
 
-		res, err := remotes.FetchRemote(ctx, fs, "git+https://gist.github.com/3f16049ce3f9f478e6b917237b2c0d88.git:/sample-toolset.json", nil, nil)
+		res, err := remotes.NewFetcher(fs, t.TempDir()).FetchRemote(ctx, "git+https://gist.github.com/3f16049ce3f9f478e6b917237b2c0d88.git:/sample-toolset.json", nil, remotes.FetchOpts{})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
+		require.NotEmpty(t, res[0].Commit)
+		require.NotEmpty(t, res[0].Hash)
+		res[0].Commit, res[0].Hash = "", ""
 		require.Equal(t, structs.RemoteSpec{
 			Source: "git+https://gist.github.com/3f16049ce3f9f478e6b917237b2c0d88.git:/sample-toolset.json",
 			Spec: structs.Spec{
-				Dir: "./bin/tools",
+				Version: structs.SpecVersion,
 				Tools: structs.Tools{
 					{Runtime: "go", Module: "golang.org/x/tools/cmd/stringer@v0.26.0", Alias: optional.Empty[string](), Tags: nil},
 					{Runtime: "go", Module: "github.com/kazhuravlev/options-gen/cmd/options-gen@v0.33.0", Alias: optional.Empty[string](), Tags: nil},
@@ -9,8 +9,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v75/github"
+	"golang.org/x/mod/semver"
 )
 
 var errAutoDiscover = errors.New("auto-discover")
@@ -119,3 +121,51 @@ func (r *Runtime) downloadAsset(ctx context.Context, owner string, repo string,
 
 	return nil
 }
+
+// getLatestAgedRelease returns the newest release that was published before cutoff. Returns nil when there are
+// no such releases.
+func (r *Runtime) getLatestAgedRelease(ctx context.Context, owner, repo string, cutoff time.Time) (*github.RepositoryRelease, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := r.github.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("list releases: %w", err)
+		}
+
+		if release := pickAgedRelease(releases, cutoff); release != nil {
+			return release, nil
+		}
+
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// pickAgedRelease returns the release with the highest version that was published before cutoff. Drafts,
+// pre-releases and non-semver tags are ignored. Tags without "v" prefix (like 14.1.0) are compared as semver too.
+func pickAgedRelease(releases []*github.RepositoryRelease, cutoff time.Time) *github.RepositoryRelease {
+	var res *github.RepositoryRelease
+	for _, release := range releases {
+		if release.GetDraft() || release.GetPrerelease() {
+			continue
+		}
+
+		tag := semverTag(release.GetTagName())
+		if !semver.IsValid(tag) || semver.Prerelease(tag) != "" {
+			continue
+		}
+
+		if release.GetPublishedAt().After(cutoff) {
+			continue
+		}
+
+		if res == nil || semver.Compare(tag, semverTag(res.GetTagName())) > 0 {
+			res = release
+		}
+	}
+
+	return res
+}
@@ -29,7 +29,7 @@ func parse(str string) (*moduleInfo, error) {
 		return nil, errors.New("invalid github path: should be owner/proj")
 	}
 
-	if !semver.IsValid(ver) {
+	if !semver.IsValid(semverTag(ver)) {
 		return nil, errors.New("non-semver versions is not supported")
 	}
 
@@ -40,3 +40,12 @@ func parse(str string) (*moduleInfo, error) {
 		Program: parts[1],
 	}, nil
 }
+
+// semverTag adds "v" prefix to tags like 14.1.0, so they can be compared by semver package.
+func semverTag(tag string) string {
+	if strings.HasPrefix(tag, "v") {
+		return tag
+	}
+
+	return "v" + tag
+}
@@ -2,8 +2,13 @@ package runtimegh
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v75/github"
 	"github.com/kazhuravlev/toolset/internal/fsh"
@@ -35,6 +40,11 @@ func TestRuntimeParse(t *testing.T) {
 				input: "owner/tool@v2.3.4",
 				want:  "owner/tool@v2.3.4",
 			},
+			{
+				name:  "tag without v prefix",
+				input: "owner/tool@14.1.0",
+				want:  "owner/tool@14.1.0",
+			},
 		}
 
 		for _, tt := range tests {
@@ -224,7 +234,7 @@ func TestParse(t *testing.T) {
 			},
 			{
 				name:    "invalid semver",
-				input:   "owner/repo@1.0.0",
+				input:   "owner/repo@release-1",
 				wantErr: "non-semver versions is not supported",
 			},
 			{
@@ -708,3 +718,95 @@ func TestAutoDiscoverAsset(t *testing.T) {
 		}
 	})
 }
+
+func TestPickAgedRelease(t *testing.T) {
+	now := time.Now()
+	release := func(tag string, age time.Duration, draft, prerelease bool) *github.RepositoryRelease {
+		return &github.RepositoryRelease{
+			TagName:     github.Ptr(tag),
+			PublishedAt: &github.Timestamp{Time: now.Add(-age)},
+			Draft:       github.Ptr(draft),
+			Prerelease:  github.Ptr(prerelease),
+		}
+	}
+
+	const day = 24 * time.Hour
+	releases := []*github.RepositoryRelease{
+		release("v1.4.0", 1*day, false, false),
+		release("v1.3.0", 10*day, true, false),
+		release("v1.3.0-rc.1", 11*day, false, true),
+		release("nightly", 12*day, false, false),
+		release("v1.2.0", 20*day, false, false),
+		release("v1.1.0", 30*day, false, false),
+	}
+
+	t.Run("skip_young_releases", func(t *testing.T) {
+		res := pickAgedRelease(releases, now.Add(-7*day))
+		require.NotNil(t, res)
+		require.Equal(t, "v1.2.0", res.GetTagName())
+	})
+
+	t.Run("all_releases_are_old_enough", func(t *testing.T) {
+		res := pickAgedRelease(releases, now)
+		require.NotNil(t, res)
+		require.Equal(t, "v1.4.0", res.GetTagName())
+	})
+
+	t.Run("all_releases_are_too_young", func(t *testing.T) {
+		require.Nil(t, pickAgedRelease(releases, now.Add(-100*day)))
+	})
+
+	t.Run("tags_without_v_prefix", func(t *testing.T) {
+		releases := []*github.RepositoryRelease{
+			release("14.2.0", 1*day, false, false),
+			release("14.1.0", 10*day, false, false),
+			release("14.0.1", 20*day, false, false),
+			release("14.1.0-rc.1", 11*day, false, false),
+		}
+
+		res := pickAgedRelease(releases, now.Add(-7*day))
+		require.NotNil(t, res)
+		require.Equal(t, "14.1.0", res.GetTagName())
+	})
+}
+
+func TestGetLatestAged(t *testing.T) {
+	now := time.Now()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/owner/tool/releases", r.URL.Path)
+		_, _ = fmt.Fprintf(w, `[
+	{"tag_name": "14.2.0", "published_at": %q},
+	{"tag_name": "14.1.0", "published_at": %q},
+	{"tag_name": "14.0.0", "published_at": %q}
+]`, now.Add(-time.Hour).Format(time.RFC3339), now.Add(-10*24*time.Hour).Format(time.RFC3339), now.Add(-20*24*time.Hour).Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(srv.Client())
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	rt := New(fsh.NewMemFS(nil), "/tmp/tools", client, "darwin", "arm64")
+	ctx := context.Background()
+	const minAge = 7 * 24 * time.Hour
+
+	t.Run("upgrade", func(t *testing.T) {
+		module, ok, err := rt.GetLatest(ctx, "owner/tool@14.0.0", minAge)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "owner/tool@14.1.0", module)
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		module, ok, err := rt.GetLatest(ctx, "owner/tool@latest", minAge)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, "owner/tool@14.1.0", module)
+	})
+
+	t.Run("do_not_downgrade", func(t *testing.T) {
+		module, ok, err := rt.GetLatest(ctx, "owner/tool@14.2.0", minAge)
+		require.NoError(t, err)
+		require.False(t, ok)
+		require.Equal(t, "owner/tool@14.2.0", module)
+	})
+}
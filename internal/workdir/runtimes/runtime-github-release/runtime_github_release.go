@@ -4,18 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v75/github"
 	"github.com/kazhuravlev/toolset/internal/archive"
+	"github.com/kazhuravlev/toolset/internal/changelog"
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/ghclient"
+	"github.com/kazhuravlev/toolset/internal/procexec"
 	"github.com/kazhuravlev/toolset/internal/workdir/structs"
-	"golang.org/x/oauth2"
+	"golang.org/x/mod/semver"
 )
 
 const (
@@ -40,6 +42,16 @@ func New(fs fsh.FS, binToolDir string, ghClient *github.Client, goos, goarch str
 	}
 }
 
+// ParseModule checks module string without access to network and returns a program name.
+func ParseModule(str string) (string, error) {
+	mod, err := parse(str)
+	if err != nil {
+		return "", err
+	}
+
+	return mod.Program, nil
+}
+
 // Parse will parse string to normal version.
 // Supported strings:
 //
@@ -205,7 +217,7 @@ func (r *Runtime) findBinary(extractedDir, binaryName string) (string, error) {
 	return "", fmt.Errorf("could not find binary %q in extracted archive", binaryName)
 }
 
-func (r *Runtime) Run(ctx context.Context, program string, args ...string) error {
+func (r *Runtime) Run(ctx context.Context, program string, opts structs.RunOpts) error {
 	mod, err := r.GetModule(ctx, program)
 	if err != nil {
 		return fmt.Errorf("get go module (%s): %w", program, err)
@@ -215,16 +227,22 @@ func (r *Runtime) Run(ctx context.Context, program string, args ...string) error
 		return fmt.Errorf("program (%s) is not installed: %w", program, structs.ErrToolNotInstalled)
 	}
 
-	programBinary := mod.BinPath
-	cmd := exec.CommandContext(ctx, programBinary, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := procexec.Cmd{
+		Path: mod.BinPath,
+		Args: opts.Args,
+		Env:  append(os.Environ(), opts.Env...),
+		Dir:  opts.Dir,
+	}
+
+	if opts.Replace {
+		if err := procexec.Exec(cmd); !errors.Is(err, procexec.ErrNotSupported) {
+			return fmt.Errorf("exec (%s): %w", program, err)
+		}
+	}
 
-	if err := cmd.Run(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			return fmt.Errorf("exit not ok (%s): %w", program, errors.Join(structs.RunError{ExitCode: exitErr.ExitCode()}, err))
+	if err := procexec.Run(ctx, cmd); err != nil {
+		if code, ok := procexec.ExitCode(err); ok {
+			return fmt.Errorf("exit not ok (%s): %w", program, errors.Join(structs.RunError{ExitCode: code}, err))
 		}
 
 		return fmt.Errorf("run (%s): %w", program, err)
@@ -233,40 +251,83 @@ func (r *Runtime) Run(ctx context.Context, program string, args ...string) error
 	return nil
 }
 
-func (r *Runtime) GetLatest(ctx context.Context, moduleReq string) (string, bool, error) {
-	mod, err := parse(moduleReq)
-	if err != nil {
-		return "", false, fmt.Errorf("parse module (%s): %w", moduleReq, err)
+// GetLatest returns the latest release of repository. When minAge is set - it returns the newest release that was
+// published at least minAge ago.
+func (r *Runtime) GetLatest(ctx context.Context, moduleReq string, minAge time.Duration) (string, bool, error) {
+	// NOTE: module can be requested without version or with "latest" version.
+	name, currentVersion, _ := strings.Cut(moduleReq, at)
+	if currentVersion == "latest" {
+		currentVersion = ""
 	}
 
-	owner, repo, ok := strings.Cut(mod.Mod.Name(), "/")
-	if !ok {
-		return "", false, fmt.Errorf("unexpected module name (%s)", mod.Mod.Name())
+	owner, repo, ok := strings.Cut(name, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", false, fmt.Errorf("unexpected module name (%s)", name)
 	}
 
-	// Get the latest release from GitHub
-	latestRelease, _, err := r.github.Repositories.GetLatestRelease(ctx, owner, repo)
-	if err != nil {
-		return "", false, fmt.Errorf("get latest release: %w", err)
+	var latestTag string
+	if minAge > 0 {
+		release, err := r.getLatestAgedRelease(ctx, owner, repo, time.Now().Add(-minAge))
+		if err != nil {
+			return "", false, fmt.Errorf("get latest aged release: %w", err)
+		}
+
+		if release == nil {
+			return moduleReq, false, nil
+		}
+
+		latestTag = release.GetTagName()
+
+		// NOTE: do not downgrade the module when current version is newer than allowed one.
+		if currentVersion != "" && semver.Compare(semverTag(latestTag), semverTag(currentVersion)) <= 0 {
+			return moduleReq, false, nil
+		}
+	} else {
+		// Get the latest release from GitHub
+		latestRelease, _, err := r.github.Repositories.GetLatestRelease(ctx, owner, repo)
+		if err != nil {
+			return "", false, fmt.Errorf("get latest release: %w", err)
+		}
+
+		latestTag = latestRelease.GetTagName()
 	}
 
-	latestTag := latestRelease.GetTagName()
 	if latestTag == "" {
 		return "", false, fmt.Errorf("latest release has no tag")
 	}
 
 	// Compare current version with latest version
-	currentVersion := mod.Mod.Version()
 	if currentVersion == latestTag {
 		return moduleReq, false, nil
 	}
 
 	// Build the new module string with latest version
-	latestModule := mod.Mod.Name() + "@" + latestTag
+	latestModule := name + "@" + latestTag
 
 	return latestModule, true, nil
 }
 
+func (r *Runtime) GetReleaseNotes(ctx context.Context, fromModule, toModule string) ([]structs.ReleaseNote, error) {
+	fromMod, err := parse(fromModule)
+	if err != nil {
+		return nil, fmt.Errorf("parse module (%s): %w", fromModule, err)
+	}
+
+	toMod, err := parse(toModule)
+	if err != nil {
+		return nil, fmt.Errorf("parse module (%s): %w", toModule, err)
+	}
+
+	owner, repo, _ := strings.Cut(toMod.Mod.Name(), "/")
+
+	notes, err := changelog.FromGithub(ctx, r.github, owner, repo, "", fromMod.Mod.Version(), toMod.Mod.Version())
+	if err != nil {
+		return nil, fmt.Errorf("get release notes: %w", err)
+	}
+
+	return notes, nil
+}
+
 func (r *Runtime) Remove(ctx context.Context, tool structs.Tool) error {
 	mod, err := r.GetModule(ctx, tool.Module)
 	if err != nil {
@@ -290,20 +351,8 @@ func (r *Runtime) Version() string {
 
 // Discover will return runtimes
 func Discover(ctx context.Context, fSys fsh.FS, binToolDir string) ([]*Runtime, error) {
-	httpClient := &http.Client{}
-
 	// Auto-auth with github_token
-	{
-		if token := os.Getenv("TOOLSET_GITHUB_TOKEN"); token != "" {
-			src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-			httpClient = oauth2.NewClient(ctx, src)
-		} else if token := os.Getenv("GITHUB_TOKEN"); token != "" {
-			src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-			httpClient = oauth2.NewClient(ctx, src)
-		}
-	}
-
-	ghClient := github.NewClient(httpClient)
+	ghClient := ghclient.New(ctx)
 
 	rt := New(fSys, binToolDir, ghClient, runtime.GOOS, runtime.GOARCH)
 
@@ -12,16 +12,21 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/kazhuravlev/toolset/internal/prog"
 	"github.com/spf13/afero"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 var reVersion = regexp.MustCompile(`^go version go(\d+\.\d+(?:\.\d+)?)(?: .*|$)`)
 
+var errNoAgedVersion = errors.New("no version that satisfies min release age")
+
 type moduleInfo struct {
 	Mod prog.Version
 
@@ -31,34 +36,9 @@ type moduleInfo struct {
 
 // parse will parse source string and try to extract all details about mentioned golang program.
 func (r *Runtime) parse(ctx context.Context, str string) (*moduleInfo, error) {
-	var mod, version, program string
-
-	{
-		parts := strings.Split(str, at)
-		switch len(parts) {
-		default:
-			return nil, errors.New("invalid format")
-		case 1: // have no version. means latest
-			version = "latest"
-		case 2: // have version. parse it
-			version = parts[1]
-		}
-
-		mod = parts[0]
-
-		// github.com/user/repo/cmd/program => program
-		if strings.Contains(mod, "/cmd/") {
-			program = filepath.Base(strings.Split(mod, "/cmd/")[1])
-		} else {
-			// github.com/user/repo/v3 => repo
-			parts := strings.Split(mod, "/")
-			lastPart := parts[len(parts)-1]
-			if strings.HasPrefix(lastPart, "v") {
-				program = parts[len(parts)-2]
-			} else {
-				program = lastPart
-			}
-		}
+	mod, version, program, err := parseModule(str)
+	if err != nil {
+		return nil, err
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -88,8 +68,50 @@ func (r *Runtime) parse(ctx context.Context, str string) (*moduleInfo, error) {
 	}, nil
 }
 
+// parseModule splits source string into module path, version and program name. It does not access network.
+func parseModule(str string) (string, string, string, error) {
+	var mod, version, program string
+
+	parts := strings.Split(str, at)
+	switch len(parts) {
+	default:
+		return "", "", "", errors.New("invalid format")
+	case 1: // have no version. means latest
+		version = "latest"
+	case 2: // have version. parse it
+		version = parts[1]
+	}
+
+	mod = parts[0]
+
+	// github.com/user/repo/cmd/program => program
+	if strings.Contains(mod, "/cmd/") {
+		program = filepath.Base(strings.Split(mod, "/cmd/")[1])
+	} else {
+		// github.com/user/repo/v3 => repo
+		parts := strings.Split(mod, "/")
+		lastPart := parts[len(parts)-1]
+		if strings.HasPrefix(lastPart, "v") && len(parts) > 1 {
+			program = parts[len(parts)-2]
+		} else {
+			program = lastPart
+		}
+	}
+
+	return mod, version, program, nil
+}
+
 type fetchedMod struct {
-	Version string `json:"Version"`
+	Version string         `json:"Version"`
+	Time    time.Time      `json:"Time"`
+	Origin  *fetchedOrigin `json:"Origin"`
+}
+
+// fetchedOrigin describes a VCS source of module version.
+type fetchedOrigin struct {
+	VCS    string `json:"VCS"`
+	URL    string `json:"URL"`
+	Subdir string `json:"Subdir"`
 }
 
 // fetchModule will fetch the module for required version. Always returns a specific version
@@ -111,56 +133,188 @@ func (r *Runtime) fetchModule(ctx context.Context, link string) (*moduleInfo, er
 		return r.parse(ctx, mod.Mod.Name()+at+privateMod.Mod.Version())
 	}
 
-	link = mod.Mod.Name()
+	_, fMod, err := r.fetchInfo(ctx, mod.Mod)
+	if err != nil {
+		return nil, err
+	}
+
+	mod2, err := r.parse(ctx, mod.Mod.Name()+at+fMod.Version)
+	if err != nil {
+		return nil, fmt.Errorf("parse fetched module: %w", err)
+	}
+
+	return mod2, nil
+}
+
+// fetchLatestAged returns the newest version of module that was published at least minAge ago. Returns
+// errNoAgedVersion when all versions are too young.
+func (r *Runtime) fetchLatestAged(ctx context.Context, mod moduleInfo, minAge time.Duration) (*moduleInfo, error) {
+	cutoff := time.Now().Add(-minAge)
+
+	modPath, latest, err := r.fetchInfo(ctx, mod.Mod.AsLatest())
+	if err != nil {
+		return nil, err
+	}
+
+	if !latest.Time.After(cutoff) {
+		return r.parse(ctx, mod.Mod.Name()+at+latest.Version)
+	}
+
+	versions, err := r.fetchVersions(ctx, modPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ver := range olderVersions(versions, latest.Version) {
+		_, info, err := r.fetchInfo(ctx, prog.NewVer(modPath, ver))
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.Time.After(cutoff) {
+			return r.parse(ctx, mod.Mod.Name()+at+info.Version)
+		}
+	}
+
+	return nil, errNoAgedVersion
+}
+
+// fetchInfo requests a version info from go proxy. Program can be placed into a subdirectory of module, so
+// this function will go up by path until it finds a module. Returns a path of found module and version info.
+func (r *Runtime) fetchInfo(ctx context.Context, mod prog.Version) (string, *fetchedMod, error) {
+	link := mod.Name()
 	for {
 		// TODO: use a local proxy if configured.
-		// Get the latest version
 		var modUrl string
-		if mod.Mod.IsLatest() {
+		if mod.IsLatest() {
 			modUrl = fmt.Sprintf("https://proxy.golang.org/%s/@latest", link)
 		} else {
-			modUrl = fmt.Sprintf("https://proxy.golang.org/%s/@v/%s.info", link, mod.Mod.Version())
+			modUrl = fmt.Sprintf("https://proxy.golang.org/%s/@v/%s.info", link, mod.Version())
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, modUrl, nil)
+		var fMod fetchedMod
+		found, err := proxyGet(ctx, modUrl, func(body io.Reader) error {
+			if err := json.NewDecoder(body).Decode(&fMod); err != nil {
+				return fmt.Errorf("unable to decode module: %w", err)
+			}
+
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("create request: %w", err)
+			return "", nil, err
+		}
+
+		if !found {
+			parts := strings.Split(link, "/")
+			if len(parts) == 1 {
+				break
+			}
+
+			link = strings.Join(parts[:len(parts)-1], "/")
+			continue
 		}
 
-		resp, err := http.DefaultClient.Do(req)
+		return link, &fMod, nil
+	}
+
+	return "", nil, errors.New("unknown module")
+}
+
+// fetchVersions returns all known versions of module.
+func (r *Runtime) fetchVersions(ctx context.Context, modPath string) ([]string, error) {
+	var versions []string
+	found, err := proxyGet(ctx, fmt.Sprintf("https://proxy.golang.org/%s/@v/list", modPath), func(body io.Reader) error {
+		bb, err := io.ReadAll(body)
 		if err != nil {
-			return nil, fmt.Errorf("get go module: %w", err)
+			return fmt.Errorf("read versions: %w", err)
 		}
-		defer resp.Body.Close() //nolint:errcheck
 
-		if resp.StatusCode != http.StatusOK {
-			if resp.StatusCode == http.StatusNotFound {
-				parts := strings.Split(link, "/")
-				if len(parts) == 1 {
-					break
-				}
+		versions = strings.Fields(string(bb))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-				link = strings.Join(parts[:len(parts)-1], "/")
-				continue
+	if !found {
+		return nil, fmt.Errorf("unknown module (%s)", modPath)
+	}
+
+	return versions, nil
+}
+
+// proxyGet requests go proxy and calls fn with response body. Returns false when proxy does not know about url.
+func proxyGet(ctx context.Context, url string, fn func(body io.Reader) error) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("get go module: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("unable to get module: %s", resp.Status)
+	}
+
+	return true, fn(resp.Body)
+}
+
+// repoOfModule returns a repository url and a tag prefix of module. It uses origin of version that was reported by
+// go proxy. For private modules (or when origin is absent) it tries to guess the repository from module path.
+func (r *Runtime) repoOfModule(ctx context.Context, mod moduleInfo) (string, string, error) {
+	if !mod.IsPrivate {
+		_, info, err := r.fetchInfo(ctx, mod.Mod)
+		if err != nil {
+			return "", "", err
+		}
+
+		if info.Origin != nil && info.Origin.VCS == "git" && info.Origin.URL != "" {
+			var tagPrefix string
+			if info.Origin.Subdir != "" {
+				tagPrefix = info.Origin.Subdir + "/"
 			}
 
-			return nil, fmt.Errorf("unable to get module: %s", resp.Status)
+			return info.Origin.URL, tagPrefix, nil
 		}
+	}
 
-		var fMod fetchedMod
-		if err := json.NewDecoder(resp.Body).Decode(&fMod); err != nil {
-			return nil, fmt.Errorf("unable to decode module: %w", err)
+	// github.com/owner/repo/cmd/program => https://github.com/owner/repo
+	parts := strings.Split(mod.Mod.Name(), "/")
+	if len(parts) >= 3 && parts[0] == "github.com" {
+		return "https://" + strings.Join(parts[:3], "/"), "", nil
+	}
+
+	return "", "", fmt.Errorf("unable to detect repository of module (%s)", mod.Mod.Name())
+}
+
+// olderVersions returns release versions that are lower than the given one. Result is sorted from newest to oldest.
+func olderVersions(versions []string, than string) []string {
+	res := make([]string, 0, len(versions))
+	for _, ver := range versions {
+		if !semver.IsValid(ver) || semver.Prerelease(ver) != "" {
+			continue
 		}
 
-		mod2, err := r.parse(ctx, mod.Mod.Name()+at+fMod.Version)
-		if err != nil {
-			return nil, fmt.Errorf("parse fetched module: %w", err)
+		if semver.Compare(ver, than) >= 0 {
+			continue
 		}
 
-		return mod2, nil
+		res = append(res, ver)
 	}
 
-	return nil, errors.New("unknown module")
+	semver.Sort(res)
+	slices.Reverse(res)
+
+	return res
 }
 
 // fetchPrivate is a hack around golang tooling. This function do next steps:
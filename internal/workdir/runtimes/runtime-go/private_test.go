@@ -102,3 +102,11 @@ func newTestRuntime(t *testing.T) *Runtime {
 
 	return rt
 }
+
+func Test_olderVersions(t *testing.T) {
+	versions := []string{"v1.0.0", "v1.2.0", "v1.10.0", "v1.3.0-rc.1", "v1.1.0", "bad", "v2.0.0"}
+
+	require.Equal(t, []string{"v1.2.0", "v1.1.0", "v1.0.0"}, olderVersions(versions, "v1.10.0"))
+	require.Equal(t, []string{}, olderVersions(versions, "v1.0.0"))
+	require.Equal(t, []string{}, olderVersions(nil, "v1.0.0"))
+}
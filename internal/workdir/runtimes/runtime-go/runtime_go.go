@@ -9,11 +9,16 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/kazhuravlev/optional"
 	"github.com/spf13/afero"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 
+	"github.com/kazhuravlev/toolset/internal/changelog"
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/procexec"
 	"github.com/kazhuravlev/toolset/internal/version"
 	"github.com/kazhuravlev/toolset/internal/workdir/structs"
 )
@@ -48,6 +53,24 @@ func New(fs fsh.FS, binToolDir, goBin, goVer string, goCache optional.Val[string
 	}, nil
 }
 
+// ParseModule checks module string without access to network and returns a program name.
+func ParseModule(str string) (string, error) {
+	mod, version, program, err := parseModule(str)
+	if err != nil {
+		return "", err
+	}
+
+	if err := module.CheckPath(mod); err != nil {
+		return "", fmt.Errorf("invalid module path: %w", err)
+	}
+
+	if version != "latest" && !semver.IsValid(version) {
+		return "", fmt.Errorf("invalid module version (%s)", version)
+	}
+
+	return program, nil
+}
+
 // Parse will parse string to normal version.
 // github.com/kazhuravlev/toolset/cmd/toolset@latest
 // github.com/kazhuravlev/toolset/cmd/toolset
@@ -107,7 +130,7 @@ func (r *Runtime) Install(ctx context.Context, program string) error {
 	return nil
 }
 
-func (r *Runtime) Run(ctx context.Context, program string, args ...string) error {
+func (r *Runtime) Run(ctx context.Context, program string, opts structs.RunOpts) error {
 	mod, err := r.GetModule(ctx, program)
 	if err != nil {
 		return fmt.Errorf("get go module (%s): %w", program, err)
@@ -117,16 +140,22 @@ func (r *Runtime) Run(ctx context.Context, program string, args ...string) error
 		return fmt.Errorf("program (%s) is not installed: %w", program, structs.ErrToolNotInstalled)
 	}
 
-	programBinary := mod.BinPath
-	cmd := exec.CommandContext(ctx, programBinary, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := procexec.Cmd{
+		Path: mod.BinPath,
+		Args: opts.Args,
+		Env:  append(os.Environ(), opts.Env...),
+		Dir:  opts.Dir,
+	}
 
-	if err := cmd.Run(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			return fmt.Errorf("exit not ok (%s): %w", program, errors.Join(structs.RunError{ExitCode: exitErr.ExitCode()}, err))
+	if opts.Replace {
+		if err := procexec.Exec(cmd); !errors.Is(err, procexec.ErrNotSupported) {
+			return fmt.Errorf("exec (%s): %w", program, err)
+		}
+	}
+
+	if err := procexec.Run(ctx, cmd); err != nil {
+		if code, ok := procexec.ExitCode(err); ok {
+			return fmt.Errorf("exit not ok (%s): %w", program, errors.Join(structs.RunError{ExitCode: code}, err))
 		}
 
 		return fmt.Errorf("run (%s): %w", program, err)
@@ -135,12 +164,32 @@ func (r *Runtime) Run(ctx context.Context, program string, args ...string) error
 	return nil
 }
 
-func (r *Runtime) GetLatest(ctx context.Context, moduleReq string) (string, bool, error) {
+// GetLatest returns the latest version of module. When minAge is set - it returns the newest version that was
+// published at least minAge ago. Private modules are not checked by minAge, because we have no publish time for them.
+func (r *Runtime) GetLatest(ctx context.Context, moduleReq string, minAge time.Duration) (string, bool, error) {
 	mod, err := r.parse(ctx, moduleReq)
 	if err != nil {
 		return "", false, fmt.Errorf("parse module (%s): %w", moduleReq, err)
 	}
 
+	if minAge > 0 && !mod.IsPrivate {
+		agedMod, err := r.fetchLatestAged(ctx, *mod, minAge)
+		if err != nil {
+			if errors.Is(err, errNoAgedVersion) {
+				return moduleReq, false, nil
+			}
+
+			return "", false, fmt.Errorf("get go module: %w", err)
+		}
+
+		// NOTE: do not downgrade the module when current version is newer than allowed one.
+		if !mod.Mod.IsLatest() && semver.Compare(agedMod.Mod.Version(), mod.Mod.Version()) <= 0 {
+			return moduleReq, false, nil
+		}
+
+		return agedMod.Mod.S(), true, nil
+	}
+
 	latestStr := mod.Mod.AsLatest().S()
 	latestMod, err := r.fetchModule(ctx, latestStr)
 	if err != nil {
@@ -154,6 +203,30 @@ func (r *Runtime) GetLatest(ctx context.Context, moduleReq string) (string, bool
 	return latestMod.Mod.S(), true, nil
 }
 
+func (r *Runtime) GetReleaseNotes(ctx context.Context, fromModule, toModule string) ([]structs.ReleaseNote, error) {
+	fromMod, err := r.parse(ctx, fromModule)
+	if err != nil {
+		return nil, fmt.Errorf("parse module (%s): %w", fromModule, err)
+	}
+
+	toMod, err := r.parse(ctx, toModule)
+	if err != nil {
+		return nil, fmt.Errorf("parse module (%s): %w", toModule, err)
+	}
+
+	repoURL, tagPrefix, err := r.repoOfModule(ctx, *toMod)
+	if err != nil {
+		return nil, fmt.Errorf("get module repository: %w", err)
+	}
+
+	notes, err := changelog.FromRepo(ctx, repoURL, tagPrefix, fromMod.Mod.Version(), toMod.Mod.Version())
+	if err != nil {
+		return nil, fmt.Errorf("get release notes: %w", err)
+	}
+
+	return notes, nil
+}
+
 func (r *Runtime) Remove(ctx context.Context, tool structs.Tool) error {
 	mod, err := r.GetModule(ctx, tool.Module)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	runtimegh "github.com/kazhuravlev/toolset/internal/workdir/runtimes/runtime-github-release"
 	runtimego "github.com/kazhuravlev/toolset/internal/workdir/runtimes/runtime-go"
@@ -32,8 +33,13 @@ type IRuntime interface {
 	GetModule(ctx context.Context, program string) (*structs.ModuleInfo, error)
 	// Install will install the program.
 	Install(ctx context.Context, program string) error
-	Run(ctx context.Context, program string, args ...string) error
-	GetLatest(ctx context.Context, module string) (string, bool, error)
+	// Run runs installed program with args and env from opts.
+	Run(ctx context.Context, program string, opts structs.RunOpts) error
+	// GetLatest returns the latest version of module and true when it differs from the given one. Versions that
+	// were published less than minAge ago are skipped. Zero minAge disables this check.
+	GetLatest(ctx context.Context, module string, minAge time.Duration) (string, bool, error)
+	// GetReleaseNotes returns release notes for versions between fromModule (exclusive) and toModule (inclusive).
+	GetReleaseNotes(ctx context.Context, fromModule, toModule string) ([]structs.ReleaseNote, error)
 	Remove(ctx context.Context, tool structs.Tool) error
 	Version() string
 }
@@ -42,6 +48,7 @@ type Runtimes struct {
 	fs         fsh.FS
 	binToolDir string
 	impls      map[string]IRuntime
+	registered []IRuntime
 }
 
 func New(fs fsh.FS, binToolDir string) (*Runtimes, error) {
@@ -61,6 +68,12 @@ func (r *Runtimes) Get(runtime string) (IRuntime, error) {
 	return rt, nil
 }
 
+// Register adds rt by its version. It replaces a discovered runtime with the same version and is kept by Discover.
+func (r *Runtimes) Register(rt IRuntime) {
+	r.registered = append(r.registered, rt)
+	r.impls[rt.Version()] = rt
+}
+
 // GetInstall will get installed runtime or try to install it in other case.
 func (r *Runtimes) GetInstall(ctx context.Context, runtime string) (IRuntime, error) {
 	runtime, err := r.EnsureInstalled(ctx, runtime)
@@ -102,6 +115,28 @@ func (r *Runtimes) EnsureInstalled(ctx context.Context, runtime string) (string,
 	}
 }
 
+// ParseModule checks runtime name and module string by rules of the runtime and returns a program name. It works
+// offline and does not require the runtime to be installed.
+func ParseModule(runtime, module string) (string, error) {
+	name, ver, hasVer := strings.Cut(runtime, "@")
+	switch name {
+	default:
+		return "", fmt.Errorf("unsupported runtime: %s", runtime)
+	case runtimeGo:
+		if hasVer && ver == "" {
+			return "", fmt.Errorf("runtime (%s) has an empty version", runtime)
+		}
+
+		return runtimego.ParseModule(module)
+	case runtimeGithub:
+		if hasVer {
+			return "", fmt.Errorf("runtime (%s) does not support versions", runtime)
+		}
+
+		return runtimegh.ParseModule(module)
+	}
+}
+
 func (r *Runtimes) List() []string {
 	keys := make([]string, 0, len(r.impls))
 	for k := range r.impls {
@@ -133,5 +168,9 @@ func (r *Runtimes) Discover(ctx context.Context) error {
 		r.impls[rt.Version()] = rt
 	}
 
+	for _, rt := range r.registered {
+		r.impls[rt.Version()] = rt
+	}
+
 	return nil
 }
@@ -48,3 +48,58 @@ func TestRuntimes(t *testing.T) {
 
 	require.Equal(t, []string{"gh", "go", "go@1.22.10"}, rt.List())
 }
+
+func TestParseModule(t *testing.T) {
+	f := func(runtime, module, exp string, isErr bool) {
+		t.Run(runtime+"_"+module, func(t *testing.T) {
+			res, err := runtimes.ParseModule(runtime, module)
+			if isErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, exp, res)
+		})
+	}
+
+	f("go", "golang.org/x/tools/cmd/goimports@v0.24.0", "goimports", false)
+	f("go@1.22.10", "github.com/golangci/golangci-lint/v2/cmd/golangci-lint@v2.5.0", "golangci-lint", false)
+	f("go", "mvdan.cc/gofumpt@v0.7.0", "gofumpt", false)
+	f("gh", "golangci/golangci-lint@v2.5.0", "golangci-lint", false)
+
+	f("golang", "mvdan.cc/gofumpt@v0.7.0", "", true)
+	f("go@", "mvdan.cc/gofumpt@v0.7.0", "", true)
+	f("gh@v1", "golangci/golangci-lint@v2.5.0", "", true)
+	f("go", "mvdan.cc/gofumpt@0.7.0", "", true)
+	f("go", "mvdan.cc/gofumpt@v0.7.0@v0.8.0", "", true)
+	f("go", "-bad/path@v1.0.0", "", true)
+	f("gh", "golangci/golangci-lint", "", true)
+	f("gh", "golangci-lint@v2.5.0", "", true)
+}
+
+type stubRuntime struct {
+	runtimes.IRuntime
+}
+
+func (stubRuntime) Version() string { return "go" }
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+
+	rt, err := runtimes.New(fsh.NewMemFS(nil), "/cache")
+	require.NoError(t, err)
+
+	rt.Register(stubRuntime{})
+
+	res, err := rt.Get("go")
+	require.NoError(t, err)
+	require.Equal(t, stubRuntime{}, res)
+
+	// Registered runtime replaces the discovered one.
+	require.NoError(t, rt.Discover(ctx))
+	res, err = rt.GetInstall(ctx, "go")
+	require.NoError(t, err)
+	require.Equal(t, stubRuntime{}, res)
+	require.Equal(t, []string{"gh", "go"}, rt.List())
+}
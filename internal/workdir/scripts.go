@@ -0,0 +1,236 @@
+package workdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kazhuravlev/toolset/internal/workdir/runtimes"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+var ErrScriptNotFound = errors.New("script not found")
+
+// ScriptInfo describes a script from spec.
+type ScriptInfo struct {
+	Name        string
+	Description string
+	Script      structs.Script
+}
+
+// ListScripts returns scripts of spec sorted by name.
+func (c *Workdir) ListScripts() []ScriptInfo {
+	res := make([]ScriptInfo, 0, len(c.spec.Scripts))
+	for _, name := range slices.Sorted(maps.Keys(c.spec.Scripts)) {
+		script := c.spec.Scripts[name]
+		res = append(res, ScriptInfo{
+			Name:        name,
+			Description: script.Description,
+			Script:      script,
+		})
+	}
+
+	return res
+}
+
+// RunScript runs the script from spec. Tools of all steps (including nested tasks) are found and installed before
+// the first step. Steps are run from the project root dir, unless step has its own dir. In dry-run mode installs and
+// runs of steps are only planned.
+func (c *Workdir) RunScript(ctx context.Context, name string) error {
+	plan, err := c.planScript(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		return c.runScript(ctx, plan)
+	}
+
+	if err := c.saveStats(ctx); err != nil {
+		return fmt.Errorf("save stats: %w", err)
+	}
+
+	if err := c.installScriptTools(ctx, plan, make(map[string]bool)); err != nil {
+		return err
+	}
+
+	return c.runScript(ctx, plan)
+}
+
+type scriptPlan struct {
+	name     string
+	parallel bool
+	steps    []stepPlan
+}
+
+// stepPlan runs a tool or a nested script.
+type stepPlan struct {
+	tool   *structs.ToolState
+	rt     runtimes.IRuntime
+	opts   structs.RunOpts
+	script *scriptPlan
+}
+
+func (c *Workdir) planScript(ctx context.Context, name string, stack []string) (*scriptPlan, error) {
+	stack = append(stack, name)
+	if slices.Contains(stack[:len(stack)-1], name) {
+		return nil, fmt.Errorf("script (%s) calls itself: %s", name, strings.Join(stack, " -> "))
+	}
+
+	script, ok := c.spec.Scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("script (%s): %w", name, ErrScriptNotFound)
+	}
+
+	res := scriptPlan{
+		name:     name,
+		parallel: script.Parallel,
+		steps:    make([]stepPlan, 0, len(script.Steps)),
+	}
+
+	for i, step := range script.Steps {
+		switch {
+		case (step.Tool == "") == (step.Task == ""):
+			return nil, fmt.Errorf("script (%s): step %d: expected one of tool or task", name, i)
+		case step.Task != "":
+			nested, err := c.planScript(ctx, step.Task, stack)
+			if err != nil {
+				return nil, err
+			}
+
+			res.steps = append(res.steps, stepPlan{script: nested})
+		default:
+			ts, rt, err := c.prepareTool(ctx, step.Tool)
+			if err != nil {
+				return nil, fmt.Errorf("script (%s): step %d: %w", name, i, err)
+			}
+
+			res.steps = append(res.steps, stepPlan{
+				tool: ts,
+				rt:   rt,
+				opts: c.stepRunOpts(ts.Tool, step),
+			})
+		}
+	}
+
+	return &res, nil
+}
+
+// stepRunOpts returns run options of the tool with args, env and dir of the step.
+func (c *Workdir) stepRunOpts(tool structs.Tool, step structs.ScriptStep) structs.RunOpts {
+	args := make([]string, 0, len(step.Args))
+	for _, arg := range step.Args {
+		args = append(args, c.expand(arg))
+	}
+
+	res := c.RunOpts(tool, args)
+
+	// NOTE: the last value of duplicated env key wins, so step env overrides tool env.
+	for _, key := range slices.Sorted(maps.Keys(step.Env)) {
+		res.Env = append(res.Env, key+"="+c.expand(step.Env[key]))
+	}
+
+	res.Dir = c.locations.ProjectRootDir
+	if step.Dir != "" {
+		res.Dir = c.expand(step.Dir)
+		if !filepath.IsAbs(res.Dir) {
+			res.Dir = filepath.Join(c.locations.ProjectRootDir, res.Dir)
+		}
+	}
+
+	return res
+}
+
+// installScriptTools installs tools that are not installed yet. Installed tools are marked in done.
+func (c *Workdir) installScriptTools(ctx context.Context, plan *scriptPlan, done map[string]bool) error {
+	for _, step := range plan.steps {
+		if step.script != nil {
+			if err := c.installScriptTools(ctx, step.script, done); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		id := step.tool.Tool.ID()
+		if step.tool.Module.IsInstalled || done[id] {
+			continue
+		}
+
+		fmt.Println("Install:", step.tool.Tool.Runtime, step.tool.Tool.Module)
+		if err := step.rt.Install(ctx, step.tool.Tool.Module); err != nil {
+			return fmt.Errorf("install tool (%s): %w", step.tool.Tool.Module, err)
+		}
+
+		done[id] = true
+	}
+
+	return nil
+}
+
+func (c *Workdir) runScript(ctx context.Context, plan *scriptPlan) error {
+	// NOTE: dry-run only plans steps, so they are planned in order.
+	if !plan.parallel || c.dryRun {
+		for _, step := range plan.steps {
+			if err := c.runStep(ctx, step); err != nil {
+				return fmt.Errorf("script (%s): %w", plan.name, err)
+			}
+		}
+
+		return nil
+	}
+
+	errs := make([]error, len(plan.steps))
+
+	var wg sync.WaitGroup
+	for i, step := range plan.steps {
+		wg.Go(func() {
+			errs[i] = c.runStep(ctx, step)
+		})
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("script (%s): %w", plan.name, err)
+	}
+
+	return nil
+}
+
+func (c *Workdir) runStep(ctx context.Context, step stepPlan) error {
+	if step.script != nil {
+		return c.runScript(ctx, step.script)
+	}
+
+	if !c.dryRun {
+		fmt.Println("Run:", step.tool.Module.Name, strings.Join(step.opts.Args, " "))
+	}
+
+	return c.runTool(ctx, step.rt, step.tool.Tool, step.opts)
+}
+
+// scriptCycle returns a chain of scripts that calls itself. Returns nil when there is no cycle.
+func scriptCycle(scripts map[string]structs.Script, name string, stack []string) []string {
+	stack = append(stack, name)
+	if slices.Contains(stack[:len(stack)-1], name) {
+		return stack
+	}
+
+	for _, step := range scripts[name].Steps {
+		if step.Task == "" {
+			continue
+		}
+
+		if cycle := scriptCycle(scripts, step.Task, stack); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
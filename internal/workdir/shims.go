@@ -0,0 +1,163 @@
+package workdir
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
+)
+
+// shimMarker is placed into every shim. Files without it are never removed from shims dir.
+const shimMarker = "Generated by toolset. DO NOT EDIT."
+
+// WriteShims writes a shim for every tool of the project into the shims dir. Shim runs the tool by `toolset run`,
+// so the locked version is used, installed on demand and recorded in stats. Shims of removed tools are deleted.
+// Returns names of shims.
+func (c *Workdir) WriteShims(ctx context.Context) ([]string, error) {
+	dir := c.locations.ShimsDir
+	if err := c.fs.MkdirAll(dir, fsh.DefaultDirPerm); err != nil {
+		return nil, fmt.Errorf("create shims dir: %w", err)
+	}
+
+	var names []string
+	for _, tool := range c.tools() {
+		if !tool.IsSupported() {
+			continue
+		}
+
+		for _, name := range toolNames(tool) {
+			if slices.Contains(names, name) {
+				continue
+			}
+
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		filename, content := shim(c.locations.ProjectRootDir, name)
+		files = append(files, filename)
+
+		path := filepath.Join(dir, filename)
+		if bb, err := afero.ReadFile(c.fs, path); err == nil && bytes.Equal(bb, content) {
+			continue
+		}
+
+		if err := afero.WriteFile(c.fs, path, content, 0o755); err != nil {
+			return nil, fmt.Errorf("write shim (%s): %w", name, err)
+		}
+	}
+
+	entries, err := afero.ReadDir(c.fs, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read shims dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || slices.Contains(files, entry.Name()) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		bb, err := afero.ReadFile(c.fs, path)
+		if err != nil {
+			return nil, fmt.Errorf("read shim: %w", err)
+		}
+
+		if !bytes.Contains(bb, []byte(shimMarker)) {
+			continue
+		}
+
+		if err := c.fs.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale shim (%s): %w", entry.Name(), err)
+		}
+	}
+
+	return names, nil
+}
+
+// ShimsDir returns a dir with shims of the project.
+func (c *Workdir) ShimsDir() string {
+	return c.locations.ShimsDir
+}
+
+// HasShims returns true when shims dir exists. Sync keeps shims up to date in this case.
+func (c *Workdir) HasShims() bool {
+	return fsh.IsExists(c.fs, c.locations.ShimsDir)
+}
+
+// Activation describes how tools of the project are put on PATH of a shell.
+type Activation struct {
+	// ShimsDir is placed first on PATH. Shims run tools by `toolset run`, so aliases, env and args of tools are
+	// applied and the same binary as `toolset which` returns is called.
+	ShimsDir string
+	// HasShims is false until shims are created by `toolset shims`.
+	HasShims bool
+}
+
+// GetActivation finds the project of dir and returns its activation. It does not discover runtimes and writes
+// nothing, so shell hooks can call it on each change of the current dir. Returns ErrSpecNotFound outside of projects.
+func GetActivation(fs fsh.FS, dir string) (*Activation, error) {
+	locations, err := getLocations(fs, dir, true)
+	if err != nil {
+		return nil, fmt.Errorf("get locations: %w", err)
+	}
+
+	return &Activation{
+		ShimsDir: locations.ShimsDir,
+		HasShims: fsh.IsExists(fs, locations.ShimsDir),
+	}, nil
+}
+
+// binDirs returns dirs of binaries of supported tools. Tools that are not installed are returned as missing.
+func (c *Workdir) binDirs(ctx context.Context, tools structs.Tools) ([]string, []structs.Tool, error) {
+	var dirs []string
+	var missing []structs.Tool
+	for _, tool := range tools {
+		if !tool.IsSupported() {
+			continue
+		}
+
+		mod, err := c.getModuleInfo(ctx, tool)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get module info: %w", err)
+		}
+
+		if !mod.IsInstalled {
+			missing = append(missing, tool)
+		}
+
+		if !slices.Contains(dirs, mod.BinDir) {
+			dirs = append(dirs, mod.BinDir)
+		}
+	}
+
+	return dirs, missing, nil
+}
+
+// shim returns a filename and content of the shim. Toolset binary can be changed by TOOLSET_BIN env.
+func shim(projectDir, name string) (string, []byte) {
+	if runtime.GOOS == "windows" {
+		content := fmt.Sprintf("@echo off\r\nrem %s\r\nif \"%%TOOLSET_BIN%%\"==\"\" set TOOLSET_BIN=toolset\r\n\"%%TOOLSET_BIN%%\" --project \"%s\" run %s %%*\r\n", shimMarker, projectDir, name)
+
+		return name + ".cmd", []byte(content)
+	}
+
+	content := fmt.Sprintf("#!/bin/sh\n# %s\nexec \"${TOOLSET_BIN:-toolset}\" --project %s run %s \"$@\"\n", shimMarker, shQuote(projectDir), shQuote(name))
+
+	return name, []byte(content)
+}
+
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,41 @@
+package structs
+
+import (
+	"reflect"
+
+	"github.com/kazhuravlev/optional"
+	"github.com/kazhuravlev/toolset/internal/jsonschema"
+)
+
+//go:generate go run ../../../cmd/toolset schema --output ../../../schema/spec.schema.json spec
+//go:generate go run ../../../cmd/toolset schema --output ../../../schema/lock.schema.json lock
+
+// SpecSchema returns JSON Schema of spec file.
+func SpecSchema() *jsonschema.Schema {
+	res := jsonschema.Reflect(Spec{}, schemaOverrides())
+	res.Title = "toolset spec"
+
+	return res
+}
+
+// LockSchema returns JSON Schema of lock file.
+func LockSchema() *jsonschema.Schema {
+	res := jsonschema.Reflect(Lock{}, schemaOverrides())
+	res.Title = "toolset lock"
+
+	return res
+}
+
+// schemaOverrides describes types with custom json encoding.
+func schemaOverrides() map[reflect.Type]jsonschema.Override {
+	return map[reflect.Type]jsonschema.Override{
+		reflect.TypeFor[optional.Val[string]](): func(*jsonschema.Schema) *jsonschema.Schema {
+			return &jsonschema.Schema{Type: []string{"string", "null"}}
+		},
+		reflect.TypeFor[ConflictPolicy](): func(s *jsonschema.Schema) *jsonschema.Schema {
+			s.Enum = []string{string(ConflictFirstWins), string(ConflictHighestVersionWins), string(ConflictError)}
+
+			return s
+		},
+	}
+}
@@ -1,9 +1,10 @@
 package structs
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"runtime"
 	"slices"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/kazhuravlev/optional"
 	"github.com/kazhuravlev/toolset/internal/prog"
+	"golang.org/x/mod/semver"
 )
 
 var ErrToolNotInstalled = errors.New("tool not installed")
@@ -23,18 +25,55 @@ func (e RunError) Error() string { return strconv.Itoa(e.ExitCode) }
 
 type Tool struct {
 	// Name of runtime
-	Runtime string `json:"runtime"`
+	Runtime string `json:"runtime" jsonschema:"required"`
 	// Path to module with version
-	Module string `json:"module"`
+	Module string `json:"module" jsonschema:"required"`
 	// Alias create a link in tools. Works like exposing some tools
 	Alias optional.Val[string] `json:"alias"`
 	Tags  []string             `json:"tags"`
+	// Hold protects the tool from upgrades.
+	Hold       bool   `json:"hold,omitempty"`
+	HoldReason string `json:"holdReason,omitempty"`
+	// Source is an include that provides this tool. Empty for tools of project spec. Filled only in lock file.
+	Source string `json:"source,omitempty"`
+	// Env is added to the process environment on each run. Values can refer to `$PROJECT_ROOT` and other env vars.
+	Env map[string]string `json:"env,omitempty"`
+	// Args are added to user's arguments on each run.
+	Args ToolArgs `json:"args,omitzero"`
+	// Platforms limits the tool to os/arch globs, like `linux/amd64`, `darwin/*` or `linux`. Empty means all
+	// platforms.
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// ToolArgs are default arguments of the tool. They can refer to `$PROJECT_ROOT` and env vars.
+type ToolArgs struct {
+	Prepend []string `json:"prepend,omitempty"`
+	Append  []string `json:"append,omitempty"`
+}
+
+// RunOpts contains everything that runtime needs to run a program.
+type RunOpts struct {
+	// Env is added to the process environment. Each item has KEY=VALUE format.
+	Env  []string
+	Args []string
+	// Dir is a working dir. Empty means the current dir.
+	Dir string
+	// Replace replaces the current process with the program when the platform supports it. Nothing is run after the
+	// program in this case.
+	Replace bool
 }
 
 func (t Tool) ID() string {
 	return fmt.Sprintf("%s:%s", t.Runtime, t.Module)
 }
 
+// ModuleVersion returns a version part of module.
+func (t Tool) ModuleVersion() string {
+	_, ver, _ := strings.Cut(t.Module, "@")
+
+	return ver
+}
+
 func (t Tool) ModuleName() string {
 	return strings.Split(t.Module, "@")[0]
 }
@@ -43,6 +82,57 @@ func (t Tool) RuntimeName() string {
 	return strings.Split(t.Runtime, "@")[0]
 }
 
+// IsMatch returns true when the name refers to this tool. Name can be a module name (or its last part) or an alias.
+func (t Tool) IsMatch(name string) bool {
+	if alias, ok := t.Alias.Get(); ok && alias == name {
+		return true
+	}
+
+	moduleName := t.ModuleName()
+
+	return moduleName == name || strings.HasSuffix(moduleName, "/"+name)
+}
+
+// SupportsPlatform returns true when the tool can be used on goos/goarch. Pattern without arch matches all
+// architectures of os.
+func (t Tool) SupportsPlatform(goos, goarch string) bool {
+	if len(t.Platforms) == 0 {
+		return true
+	}
+
+	platform := goos + "/" + goarch
+	for _, pattern := range t.Platforms {
+		if !strings.Contains(pattern, "/") {
+			pattern += "/*"
+		}
+
+		if ok, _ := path.Match(pattern, platform); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckPlatform validates a platform pattern of the tool.
+func CheckPlatform(pattern string) error {
+	goos, goarch, hasArch := strings.Cut(pattern, "/")
+	if goos == "" || (hasArch && (goarch == "" || strings.Contains(goarch, "/"))) {
+		return fmt.Errorf("invalid platform (%s): expected os or os/arch", pattern)
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid platform (%s): %w", pattern, err)
+	}
+
+	return nil
+}
+
+// IsSupported returns true when the tool can be used on the current platform.
+func (t Tool) IsSupported() bool {
+	return t.SupportsPlatform(runtime.GOOS, runtime.GOARCH)
+}
+
 // IsSame returns true when it detects that this is the same tools. It does not check tool version.
 func (t Tool) IsSame(tool Tool) bool {
 	if t.RuntimeName() != tool.RuntimeName() { // go != js
@@ -123,13 +213,74 @@ type ModuleInfo struct {
 	IsPrivate   bool
 }
 
+// Format versions of spec and lock. Older documents are upgraded by migrations package.
+const (
+	SpecVersion = 1
+	LockVersion = 1
+)
+
 type Spec struct {
-	// This dir is store all toolset-related files.
-	// This directory should be managed by toolset only.
-	// Deprecated: do not use this field. All tools stored into global cache directory.
-	Dir      string    `json:"dir,omitempty"`
+	// Schema is an optional link to JSON Schema of spec. It is used by editors.
+	Schema string `json:"$schema,omitempty"`
+	// Version is a format version. See SpecVersion.
+	Version  int       `json:"version,omitempty"`
 	Tools    Tools     `json:"tools"`
 	Includes []Include `json:"includes"`
+	// ConflictPolicy defines which version is used when includes provide different versions of the same tool.
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+	// MinReleaseAge is a cooldown for new releases, like `7d` or `36h`. Versions that are younger are skipped on
+	// upgrade.
+	MinReleaseAge string `json:"minReleaseAge,omitempty"`
+	// Scripts are named tasks that are run by `toolset task`.
+	Scripts map[string]Script `json:"scripts,omitempty"`
+	// Profiles are named sets of settings, like `dev` or `ci`. Profile is selected by `--profile`.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// Workspace makes this spec a root of monorepo. Specs of members inherit tools of the root.
+	Workspace *Workspace `json:"workspace,omitempty"`
+}
+
+// Workspace lists nested projects of monorepo.
+type Workspace struct {
+	// Members are dirs of nested specs relative to the root dir. Globs are supported, like `services/*`.
+	Members []string `json:"members" jsonschema:"required"`
+}
+
+// Profile selects a subset of tools and overrides settings. Empty fields are not changed.
+type Profile struct {
+	Description string `json:"description,omitempty"`
+	// Tags select tools for sync and upgrade when tags are not passed explicitly.
+	Tags []string `json:"tags,omitempty"`
+	// Parallel is a number of parallel installs when `--parallel` is not passed.
+	Parallel int  `json:"parallel,omitempty"`
+	Offline  bool `json:"offline,omitempty"`
+	// MinReleaseAge overrides MinReleaseAge of spec.
+	MinReleaseAge string `json:"minReleaseAge,omitempty"`
+	// Env is added to the env of all tools. It overrides env of tools.
+	Env map[string]string `json:"env,omitempty"`
+	// ConflictPolicy overrides ConflictPolicy of spec.
+	ConflictPolicy ConflictPolicy `json:"conflictPolicy,omitempty"`
+	// Frozen makes sync fail when the lock does not match the spec instead of updating the lock.
+	Frozen bool `json:"frozen,omitempty"`
+}
+
+// Script is a sequence (or a parallel group) of steps.
+type Script struct {
+	Description string `json:"description,omitempty"`
+	// Parallel runs all steps at the same time.
+	Parallel bool         `json:"parallel,omitempty"`
+	Steps    []ScriptStep `json:"steps" jsonschema:"required"`
+}
+
+// ScriptStep runs a tool or another script. Args, env and dir can refer to `$PROJECT_ROOT` and env vars.
+type ScriptStep struct {
+	// Tool is a tool name, like in `toolset run`.
+	Tool string `json:"tool,omitempty"`
+	// Task is a name of another script.
+	Task string            `json:"task,omitempty"`
+	Args []string          `json:"args,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+	// Dir is a working dir. Relative paths are resolved from the project root.
+	Dir string `json:"dir,omitempty"`
 }
 
 func (s *Spec) AddInclude(include Include) bool {
@@ -143,35 +294,127 @@ func (s *Spec) AddInclude(include Include) bool {
 	return true
 }
 
+type ConflictPolicy string
+
+const (
+	// ConflictFirstWins uses the first found version. This is a default policy.
+	ConflictFirstWins ConflictPolicy = "first-wins"
+	// ConflictHighestVersionWins uses the highest semver version. Versions that are not semver are a conflict error.
+	ConflictHighestVersionWins ConflictPolicy = "highest-version-wins"
+	// ConflictError fails on any conflict.
+	ConflictError ConflictPolicy = "error"
+)
+
+var ErrConflict = errors.New("includes have conflicting versions")
+
+// Validate checks that policy is known.
+func (p ConflictPolicy) Validate() error {
+	switch p {
+	case "", ConflictFirstWins, ConflictHighestVersionWins, ConflictError:
+		return nil
+	default:
+		return fmt.Errorf("unknown conflict policy (%s): should be one of %s, %s, %s", p, ConflictFirstWins, ConflictHighestVersionWins, ConflictError)
+	}
+}
+
+// Conflict describes a tool that has different versions in includes.
+type Conflict struct {
+	// Chosen is a tool that is used.
+	Chosen Tool
+	// Candidates are all found versions of tool. Each has a Source.
+	Candidates []Tool
+}
+
+func (c Conflict) String() string {
+	candidates := make([]string, 0, len(c.Candidates))
+	for _, tool := range c.Candidates {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", tool.ModuleVersion(), tool.Source))
+	}
+
+	return fmt.Sprintf("%s: %s => %s (%s)", c.Chosen.ModuleName(), strings.Join(candidates, ", "), c.Chosen.ModuleVersion(), c.Chosen.Source)
+}
+
+// RemoveInclude removes include by its source. Returns true when include was removed.
+func (s *Spec) RemoveInclude(src string) bool {
+	idx := slices.IndexFunc(s.Includes, func(inc Include) bool { return inc.Src == src })
+	if idx == -1 {
+		return false
+	}
+
+	s.Includes = slices.Delete(s.Includes, idx, idx+1)
+
+	return true
+}
+
+// FindInclude returns include by its source. Returns an empty include when it is not found.
+func (s *Spec) FindInclude(src string) Include {
+	for _, inc := range s.Includes {
+		if inc.Src == src {
+			return inc
+		}
+	}
+
+	return Include{Src: src}
+}
+
 type Include struct {
-	Src  string   `json:"src"`
+	Src  string   `json:"src" jsonschema:"required"`
 	Tags []string `json:"tags"`
+	// Exclude contains tools that should not be taken from this include. Tool is matched by module name, alias or tag.
+	Exclude []string `json:"exclude,omitempty"`
+	// Override changes tools that are taken from this include.
+	Override []IncludeOverride `json:"override,omitempty"`
+}
+
+// IncludeOverride changes the included tool. Empty fields are not changed.
+type IncludeOverride struct {
+	// Tool is a module name or alias of included tool.
+	Tool    string               `json:"tool" jsonschema:"required"`
+	Version string               `json:"version,omitempty"`
+	Alias   optional.Val[string] `json:"alias,omitzero"`
+	Tags    []string             `json:"tags,omitempty"`
 }
 
 func (i Include) IsSame(include Include) bool {
 	return i.Src == include.Src
 }
 
-func (i *Include) UnmarshalJSON(bb []byte) error {
-	var incStruct struct {
-		Src  string   `json:"src"`
-		Tags []string `json:"tags"`
+// Apply applies exclude and override rules to the included tool. Returns false when tool is excluded.
+func (i Include) Apply(tool Tool) (Tool, bool) {
+	for _, name := range i.Exclude {
+		if tool.IsMatch(name) || slices.Contains(tool.Tags, name) {
+			return tool, false
+		}
 	}
-	if err := json.Unmarshal(bb, &incStruct); err != nil {
-		// NOTE: Migration: probably this is an old version of include. This version is just a string.
-		var inc string
-		if errStr := json.Unmarshal(bb, &inc); errStr != nil {
-			return fmt.Errorf("unmarshal Include: %w", errors.Join(err, errStr))
+
+	for _, o := range i.Override {
+		if !tool.IsMatch(o.Tool) {
+			continue
 		}
 
-		i.Src = inc
-		i.Tags = []string{}
-		return nil
+		if o.Version != "" {
+			tool.Module = tool.ModuleName() + "@" + o.Version
+		}
+
+		if o.Alias.HasVal() {
+			tool.Alias = o.Alias
+		}
+
+		if o.Tags != nil {
+			tool.Tags = slices.Clone(o.Tags)
+		}
 	}
 
-	*i = incStruct
+	return tool, true
+}
 
-	return nil
+// ReleaseNote describes one release of the tool.
+type ReleaseNote struct {
+	Version     string
+	Name        string
+	URL         string
+	Body        string
+	PublishedAt time.Time
 }
 
 type Stats struct {
@@ -187,43 +430,40 @@ type ToolState struct {
 }
 
 type RemoteSpec struct {
-	Source string   `json:"source"`
+	Source string   `json:"source" jsonschema:"required"`
 	Spec   Spec     `json:"spec"`
 	Tags   []string `json:"tags"`
+	// Commit is a resolved commit SHA. Filled only for git sources.
+	Commit string `json:"commit,omitempty"`
+	// Hash is a checksum of source content, like `sha256:...`.
+	Hash string `json:"hash,omitempty"`
+	// Via is a chain of sources that leads to this one. Empty for sources that are included by project spec directly.
+	Via []string `json:"via,omitempty"`
 }
 
-func (r *RemoteSpec) UnmarshalJSON(bb []byte) error {
-	// NOTE(zhuravlev): Migration: from Tags to tags
-	var spec struct {
-		Source string   `json:"source"`
-		Spec   Spec     `json:"spec"`
-		Tags   []string `json:"tags"`
+// Root returns a source that was included by project spec.
+func (r RemoteSpec) Root() string {
+	if len(r.Via) != 0 {
+		return r.Via[0]
 	}
-	if err := json.Unmarshal(bb, &spec); err != nil {
-		var specOld struct {
-			Source string   `json:"Source"`
-			Spec   Spec     `json:"Spec"`
-			Tags   []string `json:"Tags"`
-		}
-		if errOld := json.Unmarshal(bb, &specOld); errOld != nil {
-			return fmt.Errorf("unmarshal RemoteSpec: %w", errors.Join(err, errOld))
-		}
 
-		*r = RemoteSpec(specOld)
-		return nil
-	}
-
-	*r = spec
-
-	return nil
+	return r.Source
 }
 
 type Lock struct {
+	// Version is a format version. See LockVersion.
+	Version int          `json:"version,omitempty"`
 	Tools   Tools        `json:"tools"`
 	Remotes []RemoteSpec `json:"remotes"`
 }
 
-func (l *Lock) FromSpec(spec *Spec) {
+// FromSpec fills lock tools from spec tools and remotes. Tools of project spec always win. Conflicts between remotes are
+// resolved by spec conflict policy.
+func (l *Lock) FromSpec(spec *Spec) ([]Conflict, error) {
+	if err := spec.ConflictPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
 	if l.Remotes == nil {
 		l.Remotes = make([]RemoteSpec, 0)
 	}
@@ -235,10 +475,100 @@ func (l *Lock) FromSpec(spec *Spec) {
 
 	// TODO(zhuravlev): should we refresh remotes from spec?
 
+	var conflicts []Conflict
 	for _, remote := range l.Remotes {
+		includes := l.IncludeChain(spec, remote)
+	nextTool:
 		for _, tool := range remote.Spec.Tools {
-			tool.Tags = append(tool.Tags, remote.Tags...)
-			l.Tools.Add(tool)
+			// NOTE: rules of the nearest include are applied first.
+			for _, inc := range slices.Backward(includes) {
+				var ok bool
+				if tool, ok = inc.Apply(tool); !ok {
+					continue nextTool
+				}
+			}
+
+			tool.Tags = append(slices.Clone(tool.Tags), remote.Tags...)
+			tool.Source = remote.Source
+
+			idx := slices.IndexFunc(l.Tools, tool.IsSame)
+			if idx == -1 {
+				l.Tools = append(l.Tools, tool)
+				continue
+			}
+
+			existing := l.Tools[idx]
+			if existing.Source == "" || existing.Module == tool.Module {
+				continue
+			}
+
+			cIdx := slices.IndexFunc(conflicts, func(c Conflict) bool { return c.Chosen.IsSame(tool) })
+			if cIdx == -1 {
+				conflicts = append(conflicts, Conflict{Candidates: []Tool{existing}})
+				cIdx = len(conflicts) - 1
+			}
+
+			conflicts[cIdx].Candidates = append(conflicts[cIdx].Candidates, tool)
+
+			if spec.ConflictPolicy == ConflictHighestVersionWins {
+				cmp, err := compareVersions(tool.ModuleVersion(), existing.ModuleVersion())
+				if err != nil {
+					return nil, fmt.Errorf("resolve conflict of %s (%s, %s): %w", tool.ModuleName(), existing.Source, tool.Source, err)
+				}
+
+				if cmp > 0 {
+					l.Tools[idx] = tool
+				}
+			}
+
+			conflicts[cIdx].Chosen = l.Tools[idx]
+		}
+	}
+
+	if len(conflicts) != 0 && spec.ConflictPolicy == ConflictError {
+		msgs := make([]string, 0, len(conflicts))
+		for _, c := range conflicts {
+			msgs = append(msgs, c.String())
+		}
+
+		return conflicts, fmt.Errorf("%w: %s", ErrConflict, strings.Join(msgs, "; "))
+	}
+
+	return conflicts, nil
+}
+
+// compareVersions compares versions by semver. Release tags like 14.1.0 are compared as v14.1.0.
+func compareVersions(a, b string) (int, error) {
+	for _, ver := range []string{a, b} {
+		if !semver.IsValid(semverVersion(ver)) {
+			return 0, fmt.Errorf("version (%s) is not comparable: %w", ver, ErrConflict)
 		}
 	}
+
+	return semver.Compare(semverVersion(a), semverVersion(b)), nil
+}
+
+func semverVersion(ver string) string {
+	if strings.HasPrefix(ver, "v") {
+		return ver
+	}
+
+	return "v" + ver
+}
+
+// IncludeChain returns includes that lead to the remote, starting from the project spec include.
+func (l *Lock) IncludeChain(spec *Spec, remote RemoteSpec) []Include {
+	chain := append(slices.Clone(remote.Via), remote.Source)
+
+	res := []Include{spec.FindInclude(chain[0])}
+	for i := 0; i < len(chain)-1; i++ {
+		idx := slices.IndexFunc(l.Remotes, func(r RemoteSpec) bool { return r.Source == chain[i] })
+		if idx == -1 {
+			continue
+		}
+
+		res = append(res, l.Remotes[idx].Spec.FindInclude(chain[i+1]))
+	}
+
+	return res
 }
@@ -1,6 +1,9 @@
 package structs_test
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kazhuravlev/optional"
@@ -51,6 +54,27 @@ func TestTool(t *testing.T) {
 			require.True(t, t1.IsSame(t2))
 		})
 	})
+
+	t.Run("SupportsPlatform", func(t *testing.T) {
+		t1 := Tool("go", "mod1", optional.Empty[string](), nil)
+		require.True(t, t1.SupportsPlatform("windows", "386"))
+
+		t1.Platforms = []string{"linux/amd64", "darwin"}
+		require.True(t, t1.SupportsPlatform("linux", "amd64"))
+		require.False(t, t1.SupportsPlatform("linux", "arm64"))
+		require.True(t, t1.SupportsPlatform("darwin", "arm64"))
+		require.False(t, t1.SupportsPlatform("windows", "amd64"))
+
+		t1.Platforms = []string{"*/arm64"}
+		require.True(t, t1.SupportsPlatform("linux", "arm64"))
+		require.False(t, t1.SupportsPlatform("linux", "amd64"))
+
+		require.NoError(t, structs.CheckPlatform("linux"))
+		require.NoError(t, structs.CheckPlatform("darwin/*"))
+		require.Error(t, structs.CheckPlatform("linux/"))
+		require.Error(t, structs.CheckPlatform("linux/amd64/v2"))
+		require.Error(t, structs.CheckPlatform("[linux"))
+	})
 }
 
 func TestTools(t *testing.T) {
@@ -114,7 +138,6 @@ func TestTools(t *testing.T) {
 
 func TestLock_FromSpec(t *testing.T) {
 	spec := structs.Spec{
-		Dir: "/tmp",
 		Tools: structs.Tools{
 			{
 				Runtime: "sample",
@@ -126,7 +149,8 @@ func TestLock_FromSpec(t *testing.T) {
 		Includes: nil,
 	}
 	lock := structs.Lock{}
-	lock.FromSpec(&spec)
+	_, err := lock.FromSpec(&spec)
+	require.NoError(t, err)
 
 	require.Equal(t, structs.Lock{
 		Tools: structs.Tools{
@@ -141,6 +165,142 @@ func TestLock_FromSpec(t *testing.T) {
 	}, lock)
 }
 
+func TestLock_FromSpec_IncludeRules(t *testing.T) {
+	spec := structs.Spec{
+		Includes: []structs.Include{
+			{
+				Src:     "company.json",
+				Tags:    []string{"company"},
+				Exclude: []string{"gofumpt", "deprecated"},
+				Override: []structs.IncludeOverride{
+					{Tool: "golangci-lint", Version: "v1.60.0", Alias: optional.New("lint"), Tags: []string{"linters"}},
+				},
+			},
+		},
+	}
+	lock := structs.Lock{
+		Remotes: []structs.RemoteSpec{
+			{
+				Source: "base.json",
+				Via:    []string{"company.json"},
+				Tags:   []string{"company"},
+				Spec: structs.Spec{
+					Tools: structs.Tools{
+						Tool("go", "golang.org/x/tools/cmd/goimports@v0.1.0", optional.Empty[string](), nil),
+						Tool("go", "golang.org/x/tools/cmd/stringer@v0.1.0", optional.Empty[string](), nil),
+					},
+				},
+			},
+			{
+				Source: "company.json",
+				Tags:   []string{"company"},
+				Spec: structs.Spec{
+					Tools: structs.Tools{
+						Tool("go", "mvdan.cc/gofumpt@v0.7.0", optional.Empty[string](), nil),
+						Tool("go", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.61.0", optional.Empty[string](), []string{"ci"}),
+						Tool("go", "github.com/some/old@v1.0.0", optional.Empty[string](), []string{"deprecated"}),
+					},
+					Includes: []structs.Include{
+						{Src: "base.json", Exclude: []string{"stringer"}},
+					},
+				},
+			},
+		},
+	}
+	conflicts, err := lock.FromSpec(&spec)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+
+	goimports := Tool("go", "golang.org/x/tools/cmd/goimports@v0.1.0", optional.Empty[string](), []string{"company"})
+	goimports.Source = "base.json"
+	golangci := Tool("go", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.60.0", optional.New("lint"), []string{"linters", "company"})
+	golangci.Source = "company.json"
+
+	require.Equal(t, structs.Tools{goimports, golangci}, lock.Tools)
+}
+
+func TestLock_FromSpec_Conflicts(t *testing.T) {
+	remote := func(source string, modules ...string) structs.RemoteSpec {
+		tools := make(structs.Tools, 0, len(modules))
+		for _, module := range modules {
+			tools = append(tools, Tool("go", module, optional.Empty[string](), nil))
+		}
+
+		return structs.RemoteSpec{Source: source, Spec: structs.Spec{Tools: tools}}
+	}
+
+	newLock := func() structs.Lock {
+		return structs.Lock{
+			Remotes: []structs.RemoteSpec{
+				remote("a.json", "example.com/lint@v1.2.0", "example.com/fmt@v1.0.0", "example.com/local@v1.0.0"),
+				remote("b.json", "example.com/lint@v1.10.0", "example.com/fmt@v1.0.0", "example.com/local@v2.0.0"),
+			},
+		}
+	}
+
+	f := func(policy structs.ConflictPolicy, expLint, expSource string) {
+		t.Run(string(policy), func(t *testing.T) {
+			spec := structs.Spec{
+				ConflictPolicy: policy,
+				Tools: structs.Tools{
+					Tool("go", "example.com/local@v3.0.0", optional.Empty[string](), nil),
+				},
+			}
+
+			lock := newLock()
+			conflicts, err := lock.FromSpec(&spec)
+			require.NoError(t, err)
+			require.Len(t, conflicts, 1)
+			require.Equal(t, "example.com/lint: v1.2.0 (a.json), v1.10.0 (b.json) => "+expLint+" ("+expSource+")", conflicts[0].String())
+
+			require.Len(t, lock.Tools, 3)
+			require.Equal(t, "example.com/local@v3.0.0", lock.Tools[0].Module)
+			require.Equal(t, "example.com/lint@"+expLint, lock.Tools[1].Module)
+			require.Equal(t, expSource, lock.Tools[1].Source)
+		})
+	}
+
+	f("", "v1.2.0", "a.json")
+	f(structs.ConflictFirstWins, "v1.2.0", "a.json")
+	f(structs.ConflictHighestVersionWins, "v1.10.0", "b.json")
+
+	t.Run("error", func(t *testing.T) {
+		lock := newLock()
+		_, err := lock.FromSpec(&structs.Spec{ConflictPolicy: structs.ConflictError})
+		require.ErrorIs(t, err, structs.ErrConflict)
+	})
+
+	t.Run("tags_without_prefix", func(t *testing.T) {
+		lock := structs.Lock{
+			Remotes: []structs.RemoteSpec{
+				remote("a.json", "example/ripgrep@9.0.0"),
+				remote("b.json", "example/ripgrep@14.1.0"),
+			},
+		}
+		_, err := lock.FromSpec(&structs.Spec{ConflictPolicy: structs.ConflictHighestVersionWins})
+		require.NoError(t, err)
+		require.Equal(t, "example/ripgrep@14.1.0", lock.Tools[0].Module)
+	})
+
+	t.Run("not_comparable", func(t *testing.T) {
+		lock := structs.Lock{
+			Remotes: []structs.RemoteSpec{
+				remote("a.json", "example.com/lint@v1.2.0"),
+				remote("b.json", "example.com/lint@latest"),
+			},
+		}
+		_, err := lock.FromSpec(&structs.Spec{ConflictPolicy: structs.ConflictHighestVersionWins})
+		require.ErrorIs(t, err, structs.ErrConflict)
+		require.ErrorContains(t, err, "version (latest) is not comparable")
+	})
+
+	t.Run("unknown_policy", func(t *testing.T) {
+		lock := newLock()
+		_, err := lock.FromSpec(&structs.Spec{ConflictPolicy: "last-wins"})
+		require.Error(t, err)
+	})
+}
+
 func Tool(runtime, module string, alias optional.Val[string], tags []string) structs.Tool {
 	return structs.Tool{
 		Runtime: runtime,
@@ -149,3 +309,19 @@ func Tool(runtime, module string, alias optional.Val[string], tags []string) str
 		Tags:    tags,
 	}
 }
+
+func TestSchemaFiles(t *testing.T) {
+	f := func(filename string, schema any) {
+		t.Run(filename, func(t *testing.T) {
+			exp, err := json.MarshalIndent(schema, "", "\t")
+			require.NoError(t, err)
+
+			bb, err := os.ReadFile(filepath.Join("..", "..", "..", "schema", filename))
+			require.NoError(t, err)
+			require.Equal(t, string(exp)+"\n", string(bb), "schema is outdated. Run `go generate ./internal/workdir/structs`")
+		})
+	}
+
+	f("spec.schema.json", structs.SpecSchema())
+	f("lock.schema.json", structs.LockSchema())
+}
@@ -0,0 +1,310 @@
+package workdir
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/kazhuravlev/toolset/internal/timeh"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
+	remotes2 "github.com/kazhuravlev/toolset/internal/workdir/remotes"
+	"github.com/kazhuravlev/toolset/internal/workdir/runtimes"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
+)
+
+// Problem is an issue that was found in spec or lock.
+type Problem struct {
+	// File is a base name of spec or lock file.
+	File string
+	// Path points to the problem place, like `tools[1]`.
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Path, p.Message)
+}
+
+// Validate checks spec and lock without access to network. It checks unknown fields, runtimes and modules of all
+// tools, name collisions between tools and that lock matches the spec.
+func (c *Workdir) Validate() []Problem {
+	specFile := filepath.Base(c.locations.ToolsetFile)
+	lockFile := filepath.Base(c.locations.ToolsetLockFile)
+
+	var res []Problem
+	add := func(file, path, msg string, args ...any) {
+		res = append(res, Problem{File: file, Path: path, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if err := decodeStrict(c.fs, c.locations.ToolsetFile, new(structs.Spec), structs.SpecVersion); err != nil {
+		add(specFile, "", "%s", err)
+	}
+
+	if err := decodeStrict(c.fs, c.locations.ToolsetLockFile, new(structs.Lock), structs.LockVersion); err != nil {
+		add(lockFile, "", "%s", err)
+	}
+
+	if c.spec.MinReleaseAge != "" {
+		if _, err := timeh.ParseDuration(c.spec.MinReleaseAge); err != nil {
+			add(specFile, "minReleaseAge", "%s", err)
+		}
+	}
+
+	if c.spec.Workspace != nil {
+		for i, pattern := range c.spec.Workspace.Members {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				add(specFile, fmt.Sprintf("workspace.members[%d]", i), "invalid pattern (%s): %s", pattern, err)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.spec.Profiles)) {
+		path := "profiles." + name
+		profile := c.spec.Profiles[name]
+		if profile.MinReleaseAge != "" {
+			if _, err := timeh.ParseDuration(profile.MinReleaseAge); err != nil {
+				add(specFile, path+".minReleaseAge", "%s", err)
+			}
+		}
+
+		if profile.Parallel < 0 {
+			add(specFile, path+".parallel", "must not be negative")
+		}
+
+		if err := profile.ConflictPolicy.Validate(); err != nil {
+			add(specFile, path+".conflictPolicy", "%s", err)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(profile.Env)) {
+			if !isValidEnvKey(key) {
+				add(specFile, path+".env", "invalid variable name (%s)", key)
+			}
+		}
+
+		for _, tag := range profile.Tags {
+			if !slices.ContainsFunc(c.tools(), func(tool structs.Tool) bool { return slices.Contains(tool.Tags, tag) }) {
+				add(specFile, path+".tags", "tag (%s) is not used by any tool", tag)
+			}
+		}
+	}
+
+	for i, tool := range c.spec.Tools {
+		path := fmt.Sprintf("tools[%d]", i)
+		if _, err := runtimes.ParseModule(tool.Runtime, tool.Module); err != nil {
+			add(specFile, path, "%s", err)
+		}
+
+		if j := slices.IndexFunc(c.spec.Tools[:i], tool.IsSame); j != -1 {
+			add(specFile, path, "duplicates tools[%d]", j)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(tool.Env)) {
+			if !isValidEnvKey(key) {
+				add(specFile, path+".env", "invalid variable name (%s)", key)
+			}
+		}
+
+		for _, pattern := range tool.Platforms {
+			if err := structs.CheckPlatform(pattern); err != nil {
+				add(specFile, path+".platforms", "%s", err)
+			}
+		}
+	}
+
+	for i, inc := range c.spec.Includes {
+		path := fmt.Sprintf("includes[%d]", i)
+		if _, err := remotes2.ParseRemote(inc.Src); err != nil {
+			add(specFile, path, "%s", err)
+		}
+
+		if !slices.ContainsFunc(c.lock.Remotes, func(remote structs.RemoteSpec) bool { return remote.Source == inc.Src }) {
+			add(lockFile, "", "include (%s) is not locked. Run `toolset sync`", inc.Src)
+		}
+	}
+
+	for _, remote := range c.lock.Remotes {
+		if !slices.ContainsFunc(c.spec.Includes, func(inc structs.Include) bool { return inc.Src == remote.Root() }) {
+			add(lockFile, "", "remote (%s) is not included by spec", remote.Source)
+		}
+
+		for i, tool := range remote.Spec.Tools {
+			if _, err := runtimes.ParseModule(tool.Runtime, tool.Module); err != nil {
+				add(lockFile, fmt.Sprintf("remotes[%s].tools[%d]", remote.Source, i), "%s", err)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.spec.Scripts)) {
+		for i, step := range c.spec.Scripts[name].Steps {
+			path := fmt.Sprintf("scripts.%s.steps[%d]", name, i)
+			switch {
+			case (step.Tool == "") == (step.Task == ""):
+				add(specFile, path, "expected one of tool or task")
+			case step.Task != "":
+				if _, ok := c.spec.Scripts[step.Task]; !ok {
+					add(specFile, path, "unknown task (%s)", step.Task)
+				}
+			case !slices.ContainsFunc(c.tools(), func(tool structs.Tool) bool { return slices.Contains(toolNames(tool), step.Tool) }):
+				add(specFile, path, "unknown tool (%s)", step.Tool)
+			}
+		}
+
+		// NOTE: cycles are reported only for scripts that are part of the cycle.
+		if cycle := scriptCycle(c.spec.Scripts, name, nil); cycle != nil && cycle[len(cycle)-1] == name {
+			add(specFile, "scripts."+name, "script calls itself: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	res = append(res, c.validateLocal()...)
+
+	for _, msg := range collisions(c.tools()) {
+		add(lockFile, "", "%s", msg)
+	}
+
+	if err := c.spec.ConflictPolicy.Validate(); err != nil {
+		add(specFile, "conflictPolicy", "%s", err)
+
+		return res
+	}
+
+	expected := structs.Lock{Remotes: c.lock.Remotes}
+	if _, err := c.resolveLock(&expected); err != nil {
+		add(specFile, "", "%s", err)
+	}
+
+	for _, msg := range lockMismatches(expected.Tools, c.lock.Tools) {
+		add(lockFile, "", "%s. Run `toolset sync`", msg)
+	}
+
+	return res
+}
+
+// decodeStrict decodes file and fails on unknown fields. Documents of older versions are not checked, because they
+// should be migrated first.
+func decodeStrict(fs afero.Fs, filename string, target any, current int) error {
+	bb, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	bb, err = specfile.ToJSON(specfile.FormatOf(filename), bb)
+	if err != nil {
+		return err
+	}
+
+	version, err := migrations.Version(bb)
+	if err != nil {
+		return err
+	}
+
+	if version < current {
+		return fmt.Errorf("format version %d is outdated. Run `toolset migrate`", version)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(bb))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode(target)
+}
+
+// collisions returns tools that are available by the same name (alias or binary name).
+func collisions(tools structs.Tools) []string {
+	var names []string
+	byName := make(map[string][]string)
+	for _, tool := range tools {
+		for _, name := range toolNames(tool) {
+			if _, ok := byName[name]; !ok {
+				names = append(names, name)
+			}
+
+			byName[name] = append(byName[name], tool.ID())
+		}
+	}
+
+	var res []string
+	for _, name := range names {
+		if ids := byName[name]; len(ids) > 1 {
+			res = append(res, fmt.Sprintf("name (%s) is used by several tools: %s", name, strings.Join(ids, ", ")))
+		}
+	}
+
+	return res
+}
+
+func isValidEnvKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "= \t")
+}
+
+// toolNames returns names that can be used to run the tool: alias and binary name.
+func toolNames(tool structs.Tool) []string {
+	res := make([]string, 0, 2)
+	if alias, ok := tool.Alias.Get(); ok {
+		res = append(res, alias)
+	}
+
+	if program, err := runtimes.ParseModule(tool.Runtime, tool.Module); err == nil && !slices.Contains(res, program) {
+		res = append(res, program)
+	}
+
+	return res
+}
+
+// lockMismatches compares expected tools with locked ones.
+func lockMismatches(expected, locked structs.Tools) []string {
+	var res []string
+	for _, tool := range expected {
+		idx := slices.IndexFunc(locked, tool.IsSame)
+		switch {
+		case idx == -1:
+			res = append(res, fmt.Sprintf("tool (%s) is not locked", tool.Module))
+		case locked[idx].Module != tool.Module:
+			res = append(res, fmt.Sprintf("tool (%s) is locked as %s", tool.Module, locked[idx].Module))
+		case !reflect.DeepEqual(normalizeTool(locked[idx]), normalizeTool(tool)):
+			res = append(res, fmt.Sprintf("tool (%s) is locked with other settings", tool.Module))
+		}
+	}
+
+	for _, tool := range locked {
+		if !slices.ContainsFunc(expected, tool.IsSame) {
+			res = append(res, fmt.Sprintf("tool (%s) is not declared in spec or includes", tool.Module))
+		}
+	}
+
+	return res
+}
+
+// normalizeTool makes empty and nil slices (and maps) equal.
+func normalizeTool(tool structs.Tool) structs.Tool {
+	if len(tool.Tags) == 0 {
+		tool.Tags = nil
+	}
+
+	if len(tool.Env) == 0 {
+		tool.Env = nil
+	}
+
+	if len(tool.Args.Prepend) == 0 {
+		tool.Args.Prepend = nil
+	}
+
+	if len(tool.Args.Append) == 0 {
+		tool.Args.Append = nil
+	}
+
+	if len(tool.Platforms) == 0 {
+		tool.Platforms = nil
+	}
+
+	return tool
+}
@@ -0,0 +1,156 @@
+package workdir
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+)
+
+type DeclarationStatus string
+
+const (
+	DeclarationUsed     DeclarationStatus = "used"
+	DeclarationLost     DeclarationStatus = "lost conflict"
+	DeclarationExcluded DeclarationStatus = "excluded"
+)
+
+// Why explains why the tool is in the lock.
+type Why struct {
+	// Locked is a tool from lock. Empty when tool is not locked (all declarations are excluded).
+	Locked       structs.Tool
+	IsLocked     bool
+	Declarations []Declaration
+}
+
+// Declaration is one place that declares the tool.
+type Declaration struct {
+	// Tool is a tool as it is declared.
+	Tool structs.Tool
+	// Source is an include that declares the tool. Empty for project spec.
+	Source string
+	// Includes is a chain of includes from project spec to the source.
+	Includes []structs.Include
+	// Result is a tool after include rules.
+	Result structs.Tool
+	Status DeclarationStatus
+	// RuleSource is an include that excluded or overrode the tool.
+	RuleSource string
+	// Tags are tags of Result with their origin.
+	Tags []TagOrigin
+}
+
+// TagOrigin is a tag and a place that added it to the tool.
+type TagOrigin struct {
+	Tag string
+	// Source is an include that added the tag. Empty for tags of the tool itself.
+	Source string
+	// IsRule is true when the tag was set by override rules of Source. Otherwise, the tag is recorded on the remote
+	// of Source in lock.
+	IsRule bool
+}
+
+// IsOverridden returns true when include rules changed the tool.
+func (d Declaration) IsOverridden() bool {
+	return d.RuleSource != "" && d.Status != DeclarationExcluded
+}
+
+// Why traces the tool through project spec, includes and their rules.
+func (c *Workdir) Why(target string) (*Why, error) {
+	var res Why
+
+	match := func(tool structs.Tool) bool { return tool.IsMatch(target) }
+	ts, err := c.FindTool(target)
+	switch {
+	case err == nil:
+		res.Locked = ts.Tool
+		res.IsLocked = true
+		match = ts.Tool.IsSame
+	case !errors.Is(err, ErrToolNotFoundInSpec):
+		return nil, fmt.Errorf("find tool: %w", err)
+	}
+
+	for _, tool := range c.spec.Tools {
+		if !match(tool) {
+			continue
+		}
+
+		res.Declarations = append(res.Declarations, Declaration{
+			Tool:   tool,
+			Result: tool,
+			Status: DeclarationUsed,
+			Tags:   tagOrigins(tool.Tags, "", false),
+		})
+	}
+
+	for _, remote := range c.lock.Remotes {
+		for _, tool := range remote.Spec.Tools {
+			if !match(tool) {
+				continue
+			}
+
+			res.Declarations = append(res.Declarations, c.traceDeclaration(res.Locked, remote, tool))
+		}
+	}
+
+	if len(res.Declarations) == 0 {
+		return nil, fmt.Errorf("tool (%s) not found: %w", target, ErrToolNotFoundInSpec)
+	}
+
+	return &res, nil
+}
+
+// traceDeclaration applies include rules to the tool in the same way as lock does.
+func (c *Workdir) traceDeclaration(locked structs.Tool, remote structs.RemoteSpec, tool structs.Tool) Declaration {
+	includes := c.lock.IncludeChain(c.spec, remote)
+	decl := Declaration{
+		Tool:     tool,
+		Source:   remote.Source,
+		Includes: includes,
+	}
+
+	result := tool
+	var tagsRule string
+	for _, inc := range slices.Backward(includes) {
+		res, ok := inc.Apply(result)
+		if !ok {
+			decl.Result = result
+			decl.Status = DeclarationExcluded
+			decl.RuleSource = inc.Src
+			decl.Tags = tagOrigins(result.Tags, tagsRule, tagsRule != "")
+			return decl
+		}
+
+		if !slices.Equal(res.Tags, result.Tags) {
+			tagsRule = inc.Src
+		}
+
+		if res.Module != result.Module || res.Alias != result.Alias || !slices.Equal(res.Tags, result.Tags) {
+			decl.RuleSource = inc.Src
+		}
+
+		result = res
+	}
+
+	decl.Tags = append(tagOrigins(result.Tags, tagsRule, tagsRule != ""), tagOrigins(remote.Tags, remote.Source, false)...)
+	result.Tags = append(slices.Clone(result.Tags), remote.Tags...)
+	result.Source = remote.Source
+
+	decl.Result = result
+	decl.Status = DeclarationLost
+	if locked.Source == result.Source && locked.Module == result.Module {
+		decl.Status = DeclarationUsed
+	}
+
+	return decl
+}
+
+func tagOrigins(tags []string, source string, isRule bool) []TagOrigin {
+	res := make([]TagOrigin, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, TagOrigin{Tag: tag, Source: source, IsRule: isRule})
+	}
+
+	return res
+}
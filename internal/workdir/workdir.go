@@ -1,16 +1,22 @@
 package workdir
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/kazhuravlev/optional"
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/kazhuravlev/toolset/internal/timeh"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
 	remotes2 "github.com/kazhuravlev/toolset/internal/workdir/remotes"
 	runtimes "github.com/kazhuravlev/toolset/internal/workdir/runtimes"
 	"github.com/kazhuravlev/toolset/internal/workdir/structs"
@@ -20,7 +26,13 @@ import (
 const (
 	// This files is placed in project root
 	specFilename = ".toolset.json"
+	specBasename = ".toolset"
 	lockFilename = ".toolset.lock.json"
+	// Local files are not committed. They contain personal tools and overrides.
+	localBasename     = ".toolset.local"
+	localLockFilename = ".toolset.local.lock.json"
+	// This dir is placed in project root
+	shimsDir = ".toolset/bin"
 	// This file is places in tools directory
 	statsFilename = "stats.json"
 
@@ -32,18 +44,58 @@ const (
 	StatsVer1 = "v1"
 )
 
+// VarProjectRoot is a variable that can be used in env and args of tools. It refers to the project root dir.
+const VarProjectRoot = "PROJECT_ROOT"
+
 var (
-	ErrToolNotFoundInSpec = errors.New("tool not found in spec")
-	ErrToolNotInstalled   = errors.New("tool not installed")
+	ErrSpecNotFound        = errors.New("unable to find spec in fs tree")
+	ErrToolNotFoundInSpec  = errors.New("tool not found in spec")
+	ErrToolNotInstalled    = errors.New("tool not installed")
+	ErrOffline             = errors.New("not available in offline mode")
+	ErrUnsupportedPlatform = errors.New("tool is not supported on this platform")
+	ErrProfileNotFound     = errors.New("profile not found")
+	ErrLockOutdated        = errors.New("lock does not match spec")
 )
 
 type Workdir struct {
 	spec      *structs.Spec
+	specRaw   []byte
+	migrated  Migrated
 	lock      *structs.Lock
 	stats     *structs.Stats
 	runtimes  *runtimes.Runtimes
+	fetcher   *remotes2.Fetcher
 	fs        fsh.FS
 	locations *Locations
+
+	dryRun      bool
+	offline     bool
+	plan        *Plan
+	profileName string
+	profile     structs.Profile
+	// parent is a workspace root when this project is a member of workspace.
+	parent *workspaceParent
+	// local is an optional untracked spec. Nil when there is no local spec.
+	local      *localSpec
+	writeLocal bool
+	// toolsOnPath puts binaries of installed tools first on PATH of running tools.
+	toolsOnPath     bool
+	toolsOnPathTags []string
+	// replace makes RunTool and Exec replace toolset process with the program.
+	replace bool
+}
+
+// Plan describes actions that were skipped because of dry-run mode.
+type Plan struct {
+	Install []structs.Tool
+	Remove  []structs.Tool
+	Run     []PlannedRun
+}
+
+// PlannedRun is a run of the tool that was skipped because of dry-run mode.
+type PlannedRun struct {
+	Tool structs.Tool
+	Args []string
 }
 
 func New(ctx context.Context, fs fsh.FS, dir string) (*Workdir, error) {
@@ -52,21 +104,36 @@ func New(ctx context.Context, fs fsh.FS, dir string) (*Workdir, error) {
 		return nil, fmt.Errorf("get locations: %w", err)
 	}
 
-	spec, err := fsh.ReadJson[structs.Spec](ctx, fs, locations.ToolsetFile)
+	if err := fs.MkdirAll(locations.CacheDir, fsh.DefaultDirPerm); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	specJSON, err := specfile.ReadJSON(ctx, fs, locations.ToolsetFile)
 	if err != nil {
 		return nil, fmt.Errorf("spec file not found: %w", err)
 	}
 
-	if spec.Dir != "" {
-		fmt.Println("'dir' parameter is deprecated. It will be removed automatically. toolset@v0.27.0 store all downloads into one global cache directory. Check TOOLSET_CACHE_DIR env.")
-		spec.Dir = ""
+	spec, specChanges, err := migrations.Spec(specJSON)
+	if err != nil {
+		return nil, fmt.Errorf("read spec (%s): %w", locations.ToolsetFile, err)
+	}
+
+	// Keep the normalized representation to avoid rewriting (and reformatting) unchanged spec.
+	specRaw, err := specfile.Marshal(specfile.FormatOf(locations.ToolsetFile), spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spec: %w", err)
 	}
 
-	lockFile, err := fsh.ReadJson[structs.Lock](ctx, fs, locations.ToolsetLockFile)
+	lockJSON, err := specfile.ReadJSON(ctx, fs, locations.ToolsetLockFile)
 	if err != nil {
 		return nil, fmt.Errorf("read lock file: %w", err)
 	}
 
+	lockFile, lockChanges, err := migrations.Lock(lockJSON)
+	if err != nil {
+		return nil, fmt.Errorf("read lock (%s): %w", locations.ToolsetLockFile, err)
+	}
+
 	statsFile, err := fsh.ReadOrCreateJson(ctx, fs, locations.StatsFile, structs.Stats{
 		Version:        StatsVer1,
 		ToolsByWorkdir: make(map[string]map[string]time.Time),
@@ -84,15 +151,42 @@ func New(ctx context.Context, fs fsh.FS, dir string) (*Workdir, error) {
 		return nil, fmt.Errorf("discover runtimes: %w", err)
 	}
 
+	includeTTL, err := getIncludeTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher := remotes2.NewFetcher(fs, locations.CacheDir)
+	fetcher.SetTTL(includeTTL)
+
+	parent, err := findWorkspaceParent(ctx, fs, locations.ProjectRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("find workspace root: %w", err)
+	}
+
+	local, err := readLocal(ctx, fs, locations)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Workdir{
 		fs:        fs,
 		locations: locations,
 		spec:      spec,
+		specRaw:   specRaw,
 		lock:      lockFile,
+		migrated: Migrated{
+			Spec: specChanges,
+			Lock: lockChanges,
+		},
 		// TODO(zhuravlev): prevent simultaneous access to stats file by several programs.
 		// 	Use github.com/gofrs/flock or similar.
 		stats:    statsFile,
 		runtimes: rnTimes,
+		fetcher:  fetcher,
+		parent:   parent,
+		local:    local,
+		plan:     new(Plan),
 	}, nil
 }
 
@@ -103,6 +197,14 @@ func Init(ctx context.Context, fs fsh.FS, dir string) error {
 		return fmt.Errorf("get locations: %w", err)
 	}
 
+	if err := fs.MkdirAll(locations.CacheDir, fsh.DefaultDirPerm); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+
+	if filename, ok := findSpecFile(fs, filepath.Dir(locations.ToolsetFile)); ok {
+		return fmt.Errorf("spec already exists (%s)", filepath.Base(filename))
+	}
+
 	switch _, err := fs.Stat(locations.ToolsetFile); {
 	default:
 		return fmt.Errorf("check target spec file exists: %w", err)
@@ -110,7 +212,7 @@ func Init(ctx context.Context, fs fsh.FS, dir string) error {
 		return errors.New("spec already exists")
 	case os.IsNotExist(err):
 		spec := structs.Spec{
-			Dir:      "",
+			Version:  structs.SpecVersion,
 			Tools:    make(structs.Tools, 0),
 			Includes: make([]structs.Include, 0),
 		}
@@ -119,6 +221,7 @@ func Init(ctx context.Context, fs fsh.FS, dir string) error {
 		}
 
 		lock := structs.Lock{
+			Version: structs.LockVersion,
 			Tools:   make(structs.Tools, 0),
 			Remotes: make([]structs.RemoteSpec, 0),
 		}
@@ -138,15 +241,87 @@ func Init(ctx context.Context, fs fsh.FS, dir string) error {
 	}
 }
 
+// SetDryRun enables dry-run mode. In this mode workdir does not install, remove or run tools, but collects them
+// into Plan. Use it together with fsh.OverlayFS to keep spec and lock files untouched.
+func (c *Workdir) SetDryRun(enabled bool) {
+	c.dryRun = enabled
+	c.fetcher.SetDryRun(enabled)
+}
+
+// ClearCache removes the cache dir. In dry-run mode the cache dir is kept.
+func (c *Workdir) ClearCache() error {
+	if c.dryRun {
+		return nil
+	}
+
+	return c.fs.RemoveAll(c.locations.CacheDir)
+}
+
+// SetReplace makes RunTool and Exec replace toolset process with the program, so signals and exit code of the
+// program are handled by the caller directly. Platforms without process replacement run the program as a child.
+func (c *Workdir) SetReplace(enabled bool) {
+	c.replace = enabled
+}
+
+// SetOffline enables offline mode. Includes are served from cache, and upgrades are not available.
+func (c *Workdir) SetOffline(enabled bool) {
+	c.offline = enabled
+	c.fetcher.SetOffline(enabled)
+}
+
+// SetProfile selects a profile from spec. Empty name disables profile. Profile can enable offline mode.
+func (c *Workdir) SetProfile(name string) error {
+	if name == "" {
+		c.profileName, c.profile = "", structs.Profile{}
+		return nil
+	}
+
+	profile, ok := c.spec.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile (%s): %w", name, ErrProfileNotFound)
+	}
+
+	c.profileName, c.profile = name, profile
+	if profile.Offline {
+		c.SetOffline(true)
+	}
+
+	return nil
+}
+
+// Profile returns the selected profile and its name. Name is empty when profile is not selected.
+func (c *Workdir) Profile() (string, structs.Profile) {
+	return c.profileName, c.profile
+}
+
+// Plan returns actions that were skipped in dry-run mode.
+func (c *Workdir) Plan() Plan {
+	return *c.plan
+}
+
+// IsProjectFile returns true when the file is a spec or lock file.
+func IsProjectFile(path string) bool {
+	base := filepath.Base(path)
+	if base == lockFilename || base == localLockFilename {
+		return true
+	}
+
+	return slices.Contains(specFilenames(), base) || slices.Contains(localFilenames(), base)
+}
+
 func (c *Workdir) Save(ctx context.Context) error {
-	if err := fsh.WriteJson(ctx, c.fs, *c.spec, c.locations.ToolsetFile); err != nil {
-		return fmt.Errorf("write spec: %w", err)
+	if err := c.saveSpec(ctx); err != nil {
+		return err
 	}
 
 	if err := fsh.WriteJson(ctx, c.fs, *c.lock, c.locations.ToolsetLockFile); err != nil {
 		return fmt.Errorf("write lock: %w", err)
 	}
 
+	if err := c.saveLocal(ctx); err != nil {
+		return err
+	}
+
 	if err := c.saveStats(ctx); err != nil {
 		return fmt.Errorf("save stats: %w", err)
 	}
@@ -154,9 +329,76 @@ func (c *Workdir) Save(ctx context.Context) error {
 	return nil
 }
 
+// saveSpec writes spec in its source format. Unchanged spec is not written to keep comments and formatting.
+func (c *Workdir) saveSpec(ctx context.Context) error {
+	bb, err := specfile.Marshal(specfile.FormatOf(c.locations.ToolsetFile), c.spec)
+	if err != nil {
+		return fmt.Errorf("marshal spec: %w", err)
+	}
+
+	if bytes.Equal(bb, c.specRaw) {
+		return nil
+	}
+
+	if err := specfile.Write(ctx, c.fs, c.spec, c.locations.ToolsetFile); err != nil {
+		return fmt.Errorf("write spec: %w", err)
+	}
+
+	c.specRaw = bb
+
+	return nil
+}
+
+// Migrated describes changes that were made by migrations on load.
+type Migrated struct {
+	Spec []migrations.Change
+	Lock []migrations.Change
+}
+
+func (m Migrated) IsEmpty() bool {
+	return len(m.Spec) == 0 && len(m.Lock) == 0
+}
+
+// Migrate returns changes that were made by migrations on load. Spec is written on Save even when migrations did
+// not change anything except the format version.
+func (c *Workdir) Migrate() Migrated {
+	c.specRaw = nil
+
+	return c.migrated
+}
+
+// Convert writes spec in another format and removes the old spec file (except in dry-run mode). It returns a path
+// to the new file.
+func (c *Workdir) Convert(ctx context.Context, format specfile.Format) (string, error) {
+	oldFilename := c.locations.ToolsetFile
+	if specfile.FormatOf(oldFilename) == format {
+		return "", fmt.Errorf("spec is already in %s format", format)
+	}
+
+	newFilename := filepath.Join(filepath.Dir(oldFilename), specBasename+format.Ext())
+	if fsh.IsExists(c.fs, newFilename) {
+		return "", fmt.Errorf("file already exists (%s)", newFilename)
+	}
+
+	if err := specfile.Write(ctx, c.fs, c.spec, newFilename); err != nil {
+		return "", fmt.Errorf("write spec: %w", err)
+	}
+
+	if !c.dryRun {
+		if err := c.fs.Remove(oldFilename); err != nil {
+			return "", fmt.Errorf("remove old spec: %w", err)
+		}
+	}
+
+	c.locations.ToolsetFile = newFilename
+	c.specRaw = nil
+
+	return newFilename, nil
+}
+
 func (c *Workdir) AddInclude(ctx context.Context, source string, tags []string) (int, error) {
 	// Check that source is exists and valid.
-	remotes, err := remotes2.FetchRemote(ctx, c.fs, source, tags, nil)
+	remotes, err := c.fetcher.FetchRemote(ctx, source, tags, remotes2.FetchOpts{})
 	if err != nil {
 		return 0, fmt.Errorf("fetch spec: %w", err)
 	}
@@ -168,7 +410,9 @@ func (c *Workdir) AddInclude(ctx context.Context, source string, tags []string)
 
 	c.lock.Remotes = append(c.lock.Remotes, remotes...)
 
-	c.lock.FromSpec(c.spec)
+	if err := c.refreshLock(); err != nil {
+		return 0, err
+	}
 
 	var count int
 	for _, remote := range remotes {
@@ -184,9 +428,9 @@ func (c *Workdir) Add(ctx context.Context, runtime, program string, alias option
 		return false, "", fmt.Errorf("get runtime: %w", err)
 	}
 
-	program, err = rt.Parse(ctx, program)
+	program, err = c.parseProgram(ctx, rt, program)
 	if err != nil {
-		return false, "", fmt.Errorf("parse program: %w", err)
+		return false, "", err
 	}
 
 	tool := structs.Tool{
@@ -195,9 +439,11 @@ func (c *Workdir) Add(ctx context.Context, runtime, program string, alias option
 		Alias:   alias,
 		Tags:    tags,
 	}
-	wasAdded := c.spec.Tools.Add(tool)
+	wasAdded := c.targetSpec().Tools.Add(tool)
 	if wasAdded {
-		c.lock.FromSpec(c.spec)
+		if err := c.refreshLock(); err != nil {
+			return false, "", err
+		}
 	}
 
 	return wasAdded, program, nil
@@ -210,9 +456,9 @@ func (c *Workdir) Ensure(ctx context.Context, runtime, program string, alias opt
 		return "", fmt.Errorf("get runtime: %w", err)
 	}
 
-	program, err = rt.Parse(ctx, program)
+	program, err = c.parseProgram(ctx, rt, program)
 	if err != nil {
-		return "", fmt.Errorf("parse program: %w", err)
+		return "", err
 	}
 
 	tool := structs.Tool{
@@ -221,8 +467,40 @@ func (c *Workdir) Ensure(ctx context.Context, runtime, program string, alias opt
 		Alias:   alias,
 		Tags:    tags,
 	}
-	c.spec.Tools.UpsertTool(tool)
-	c.lock.FromSpec(c.spec)
+	c.targetSpec().Tools.UpsertTool(tool)
+	if err := c.refreshLock(); err != nil {
+		return "", err
+	}
+
+	return program, nil
+}
+
+// parseProgram parses a program by runtime. When the latest version is requested and min release age is
+// configured - it picks the newest version that is old enough.
+func (c *Workdir) parseProgram(ctx context.Context, rt runtimes.IRuntime, program string) (string, error) {
+	minAge, err := getMinReleaseAge(c.spec, c.profile)
+	if err != nil {
+		return "", err
+	}
+
+	wantLatest := !strings.Contains(program, "@") || strings.HasSuffix(program, "@latest")
+	if wantLatest && minAge > 0 {
+		module, ok, err := rt.GetLatest(ctx, program, minAge)
+		if err != nil {
+			return "", fmt.Errorf("get latest module: %w", err)
+		}
+
+		if !ok {
+			return "", fmt.Errorf("program (%s) has no versions older than %s", program, timeh.Duration(minAge))
+		}
+
+		program = module
+	}
+
+	program, err = rt.Parse(ctx, program)
+	if err != nil {
+		return "", fmt.Errorf("parse program: %w", err)
+	}
 
 	return program, nil
 }
@@ -233,7 +511,9 @@ func (c *Workdir) RemoveTool(ctx context.Context, target string) error {
 		return fmt.Errorf("find tool: %w", err)
 	}
 
-	if ts.Module.IsInstalled {
+	if ts.Module.IsInstalled && c.dryRun {
+		c.plan.Remove = append(c.plan.Remove, ts.Tool)
+	} else if ts.Module.IsInstalled {
 		rt, err := c.runtimes.Get(ts.Tool.Runtime)
 		if err != nil {
 			return fmt.Errorf("get runtime: %w", err)
@@ -244,8 +524,13 @@ func (c *Workdir) RemoveTool(ctx context.Context, target string) error {
 		}
 	}
 
-	_ = c.lock.Tools.Remove(ts.Tool)
-	_ = c.spec.Tools.Remove(ts.Tool)
+	if c.local != nil && ts.Tool.Source == c.localSource() {
+		_ = c.local.lock.Tools.Remove(ts.Tool)
+		_ = c.local.spec.Tools.Remove(ts.Tool)
+	} else {
+		_ = c.lock.Tools.Remove(ts.Tool)
+		_ = c.spec.Tools.Remove(ts.Tool)
+	}
 	delete(c.stats.ToolsByWorkdir[c.locations.ProjectRootDir], ts.Tool.ID())
 
 	return nil
@@ -253,7 +538,7 @@ func (c *Workdir) RemoveTool(ctx context.Context, target string) error {
 
 func (c *Workdir) FindTool(name string) (*structs.ToolState, error) {
 	mName, _, _ := strings.Cut(name, "@")
-	for _, tool := range c.lock.Tools {
+	for _, tool := range c.tools() {
 		mod, err := c.getModuleInfo(context.TODO(), tool)
 		if err != nil {
 			return nil, fmt.Errorf("get module (%s) info: %w", tool.Module, err)
@@ -282,18 +567,69 @@ func (c *Workdir) FindTool(name string) (*structs.ToolState, error) {
 	return nil, fmt.Errorf("tool (%s) not found: %w", name, ErrToolNotFoundInSpec)
 }
 
+// SetHold will hold (or unhold) the tool of spec or local spec. Held tools are skipped by Upgrade.
+func (c *Workdir) SetHold(target string, hold bool, reason string) error {
+	ts, err := c.FindTool(target)
+	if err != nil {
+		return fmt.Errorf("find tool: %w", err)
+	}
+
+	spec := c.spec
+	if c.local != nil && ts.Tool.Source == c.localSource() {
+		spec = c.local.spec
+	}
+
+	idx := slices.IndexFunc(spec.Tools, ts.Tool.IsSame)
+	if idx == -1 {
+		return fmt.Errorf("tool (%s) is added by %s: %w", target, ts.Tool.Source, ErrToolNotFoundInSpec)
+	}
+
+	tool := spec.Tools[idx]
+	tool.Hold = hold
+	tool.HoldReason = ""
+	if hold {
+		tool.HoldReason = reason
+	}
+
+	spec.Tools[idx] = tool
+
+	return c.refreshLock()
+}
+
 // RunTool will run a tool by its name and args.
 func (c *Workdir) RunTool(ctx context.Context, str string, args ...string) error {
-	const autoInstallProgram = true
+	ts, rt, err := c.prepareTool(ctx, str)
+	if err != nil {
+		return err
+	}
 
+	if !c.dryRun {
+		if err := c.saveStats(ctx); err != nil {
+			return fmt.Errorf("save stats: %w", err)
+		}
+	}
+
+	// NOTE: stats are saved already, so toolset can be replaced by the tool.
+	opts := c.RunOpts(ts.Tool, args)
+	opts.Replace = c.replace
+
+	return c.runTool(ctx, rt, ts.Tool, opts)
+}
+
+// prepareTool finds the tool, checks its platform and marks the tool as used. Stats should be saved by caller.
+func (c *Workdir) prepareTool(ctx context.Context, str string) (*structs.ToolState, runtimes.IRuntime, error) {
 	ts, err := c.FindTool(str)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	if !ts.Tool.IsSupported() {
+		return nil, nil, fmt.Errorf("tool (%s) supports only %s, current platform is %s: %w", ts.Tool.Module, strings.Join(ts.Tool.Platforms, ", "), currentPlatform(), ErrUnsupportedPlatform)
 	}
 
 	rt, err := c.runtimes.GetInstall(ctx, ts.Tool.Runtime)
 	if err != nil {
-		return fmt.Errorf("get or install runtime: %w", err)
+		return nil, nil, fmt.Errorf("get or install runtime: %w", err)
 	}
 
 	if _, ok := c.stats.ToolsByWorkdir[c.locations.ProjectRootDir]; !ok {
@@ -301,16 +637,46 @@ func (c *Workdir) RunTool(ctx context.Context, str string, args ...string) error
 	}
 
 	c.stats.ToolsByWorkdir[c.locations.ProjectRootDir][ts.Tool.ID()] = time.Now()
-	if err := c.saveStats(ctx); err != nil {
-		return fmt.Errorf("save stats: %w", err)
+
+	return ts, rt, nil
+}
+
+// runTool runs the tool and installs it when it is not installed. In dry-run mode the install and the run are only
+// planned.
+func (c *Workdir) runTool(ctx context.Context, rt runtimes.IRuntime, tool structs.Tool, opts structs.RunOpts) error {
+	const autoInstallProgram = true
+
+	if c.dryRun {
+		mod, err := rt.GetModule(ctx, tool.Module)
+		if err != nil {
+			return fmt.Errorf("get module (%s) info: %w", tool.Module, err)
+		}
+
+		if !mod.IsInstalled {
+			c.planInstall(tool)
+		}
+
+		c.plan.Run = append(c.plan.Run, PlannedRun{Tool: tool, Args: opts.Args})
+
+		return nil
+	}
+
+	if c.toolsOnPath {
+		_, env, err := c.toolsPathEnv(ctx, c.toolsOnPathTags)
+		if err != nil {
+			return err
+		}
+
+		// NOTE: the last value of duplicated env key wins, so this PATH overrides PATH from tool env.
+		opts.Env = append(opts.Env, env)
 	}
 
 RunProgram:
-	if err := rt.Run(ctx, ts.Tool.Module, args...); err != nil {
+	if err := rt.Run(ctx, tool.Module, opts); err != nil {
 		if errors.Is(err, structs.ErrToolNotInstalled) {
 			if autoInstallProgram {
-				if err := rt.Install(ctx, ts.Tool.Module); err != nil {
-					return fmt.Errorf("auto-install not-installed program (%s) before run: %w", ts.Tool.Module, err)
+				if err := rt.Install(ctx, tool.Module); err != nil {
+					return fmt.Errorf("auto-install not-installed program (%s) before run: %w", tool.Module, err)
 				}
 
 				goto RunProgram
@@ -330,15 +696,76 @@ RunProgram:
 	return nil
 }
 
+// RunOpts returns env and args that are used to run the tool. Env and default args of the tool are expanded:
+// `$PROJECT_ROOT` refers to the project root dir, other variables are taken from the process env. Env of the selected
+// profile is added after env of the tool.
+func (c *Workdir) RunOpts(tool structs.Tool, args []string) structs.RunOpts {
+	res := structs.RunOpts{
+		Env:  make([]string, 0, len(tool.Env)+len(c.profile.Env)),
+		Args: make([]string, 0, len(tool.Args.Prepend)+len(args)+len(tool.Args.Append)),
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(tool.Env)) {
+		res.Env = append(res.Env, key+"="+c.expand(tool.Env[key]))
+	}
+
+	// NOTE: the last value of duplicated env key wins, so profile env overrides tool env.
+	for _, key := range slices.Sorted(maps.Keys(c.profile.Env)) {
+		res.Env = append(res.Env, key+"="+c.expand(c.profile.Env[key]))
+	}
+
+	for _, arg := range tool.Args.Prepend {
+		res.Args = append(res.Args, c.expand(arg))
+	}
+
+	res.Args = append(res.Args, args...)
+
+	for _, arg := range tool.Args.Append {
+		res.Args = append(res.Args, c.expand(arg))
+	}
+
+	return res
+}
+
+// expand replaces `$PROJECT_ROOT` and env vars in s.
+func (c *Workdir) expand(s string) string {
+	return os.Expand(s, func(key string) string {
+		if key == VarProjectRoot {
+			return c.locations.ProjectRootDir
+		}
+
+		return os.Getenv(key)
+	})
+}
+
 // Sync will read the locked tools and try to install the desired version. It will skip the installation in
 // case when we have a desired version.
 func (c *Workdir) Sync(ctx context.Context, maxWorkers int, tags []string) error {
-	c.lock.FromSpec(c.spec)
+	if c.profile.Frozen {
+		if err := c.checkLock(); err != nil {
+			return err
+		}
+	}
 
-	errs := make(chan error, len(c.spec.Tools))
+	if err := c.syncIncludes(ctx); err != nil {
+		return err
+	}
+
+	if err := c.refreshLock(); err != nil {
+		return err
+	}
+
+	tools := c.tools()
+	tools = tools.Filter(tags)
+	errs := make(chan error, len(tools))
 
 	sem := semaphore.NewWeighted(int64(maxWorkers))
-	for _, tool := range c.lock.Tools.Filter(tags) {
+	for _, tool := range tools {
+		if !tool.IsSupported() {
+			fmt.Println("Skip:", tool.Runtime, tool.Module, "is not supported on", currentPlatform())
+			continue
+		}
+
 		fmt.Println("Sync:", tool.Runtime, tool.Module, tool.Alias.ValDefault(""))
 
 		rt, err := c.runtimes.GetInstall(ctx, tool.Runtime)
@@ -355,6 +782,11 @@ func (c *Workdir) Sync(ctx context.Context, maxWorkers int, tags []string) error
 			continue
 		}
 
+		if c.dryRun {
+			c.planInstall(tool)
+			continue
+		}
+
 		if err := sem.Acquire(ctx, 1); err != nil {
 			return fmt.Errorf("acquire semaphore: %w", err)
 		}
@@ -408,35 +840,184 @@ func (c *Workdir) Sync(ctx context.Context, maxWorkers int, tags []string) error
 		return fmt.Errorf("errors encountered during sync: %w", errors.Join(allErrors...))
 	}
 
+	// NOTE: shims are refreshed only when they were created by `toolset shims`.
+	if c.HasShims() {
+		if _, err := c.WriteShims(ctx); err != nil {
+			return fmt.Errorf("write shims: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// Upgrade will upgrade only spec tools. and re-fetch latest versions of includes.
-func (c *Workdir) Upgrade(ctx context.Context, filter func(structs.Tool) bool) error {
-	targetTools := make([]structs.Tool, 0, len(c.spec.Tools))
-	for _, tool := range c.spec.Tools {
-		if !filter(tool) {
+// planInstall adds the tool to plan of dry-run. Plan is shared between workspace projects, so the same install is
+// planned once.
+func (c *Workdir) planInstall(tool structs.Tool) {
+	if !slices.ContainsFunc(c.plan.Install, func(t structs.Tool) bool { return t.ID() == tool.ID() }) {
+		c.plan.Install = append(c.plan.Install, tool)
+	}
+}
+
+// refreshLock rebuilds lock tools from spec and prints conflicts between includes.
+func (c *Workdir) refreshLock() error {
+	conflicts, err := c.resolveLock(c.lock)
+	if err != nil {
+		return fmt.Errorf("resolve tools: %w", err)
+	}
+
+	if c.local != nil {
+		c.local.lock.Tools = c.resolveLocalLock()
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Println("Warning: conflicting versions:", conflict)
+	}
+
+	return nil
+}
+
+// checkLock returns ErrLockOutdated when includes of spec are not locked or the lock has other tools than the spec
+// resolves to. It is used by frozen profiles.
+func (c *Workdir) checkLock() error {
+	for _, inc := range c.spec.Includes {
+		if !slices.ContainsFunc(c.lock.Remotes, func(remote structs.RemoteSpec) bool { return remote.Source == inc.Src }) {
+			return fmt.Errorf("include (%s) is not locked: %w", inc.Src, ErrLockOutdated)
+		}
+	}
+
+	expected := structs.Lock{Remotes: c.lock.Remotes}
+	if _, err := c.resolveLock(&expected); err != nil {
+		return fmt.Errorf("resolve tools: %w", err)
+	}
+
+	if problems := lockMismatches(expected.Tools, c.lock.Tools); len(problems) != 0 {
+		return fmt.Errorf("%w: %s. Run sync without frozen profile and commit the lock", ErrLockOutdated, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// syncIncludes fetches spec includes that are not locked yet. Git sources that were already locked are fetched at
+// the locked commit. Refs are moved only by Upgrade.
+func (c *Workdir) syncIncludes(ctx context.Context) error {
+	pinned := make(map[string]string, len(c.lock.Remotes))
+	for _, remote := range c.lock.Remotes {
+		if remote.Commit != "" {
+			pinned[remote.Source] = remote.Commit
+		}
+	}
+
+	for _, inc := range c.spec.Includes {
+		isLocked := slices.ContainsFunc(c.lock.Remotes, func(remote structs.RemoteSpec) bool {
+			return remote.Source == inc.Src
+		})
+		if isLocked {
+			continue
+		}
+
+		remotes, err := c.fetcher.FetchRemote(ctx, inc.Src, inc.Tags, remotes2.FetchOpts{Pinned: pinned})
+		if err != nil {
+			return fmt.Errorf("fetch remotes: %w", err)
+		}
+
+		for _, remote := range remotes {
+			isLocked := slices.ContainsFunc(c.lock.Remotes, func(r structs.RemoteSpec) bool {
+				return r.Source == remote.Source
+			})
+			if !isLocked {
+				c.lock.Remotes = append(c.lock.Remotes, remote)
+			}
+		}
+	}
+
+	return nil
+}
+
+// printRemoteChanges prints a message when the remote differs from the locked one.
+func (c *Workdir) printRemoteChanges(remote structs.RemoteSpec) {
+	for _, locked := range c.lock.Remotes {
+		if locked.Source != remote.Source || locked.Hash == "" || locked.Hash == remote.Hash {
 			continue
 		}
-		targetTools = append(targetTools, tool)
+
+		if locked.Commit != "" && locked.Commit != remote.Commit {
+			fmt.Printf(">>> Include changed: %s (%s -> %s)\n", remote.Source, shortCommit(locked.Commit), shortCommit(remote.Commit))
+		} else {
+			fmt.Println(">>> Include changed:", remote.Source)
+		}
+	}
+}
+
+// Upgraded describes a tool that was upgraded.
+type Upgraded struct {
+	// Tool is an upgraded tool with a new module version.
+	Tool structs.Tool
+	// From is a module before upgrade.
+	From string
+}
+
+// Upgrade will upgrade only tools of spec and local spec, and re-fetch latest versions of includes. Tools are
+// written back to the spec that declares them. Returns upgraded tools.
+func (c *Workdir) Upgrade(ctx context.Context, filter func(structs.Tool) bool) ([]Upgraded, error) {
+	if c.offline {
+		return nil, fmt.Errorf("upgrade: %w", ErrOffline)
+	}
+
+	specs := []*structs.Spec{c.spec}
+	if c.local != nil {
+		specs = append(specs, c.local.spec)
+	}
+
+	type target struct {
+		tool structs.Tool
+		spec *structs.Spec
+	}
+
+	var targetTools []target
+	for _, spec := range specs {
+		for _, tool := range spec.Tools {
+			if !filter(tool) {
+				continue
+			}
+			targetTools = append(targetTools, target{tool: tool, spec: spec})
+		}
 	}
 
 	if len(targetTools) == 0 {
-		return fmt.Errorf("no tools to upgrade")
+		return nil, fmt.Errorf("no tools to upgrade")
+	}
+
+	minAge, err := getMinReleaseAge(c.spec, c.profile)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, tool := range targetTools {
+	var upgraded []Upgraded
+
+	for _, target := range targetTools {
+		tool := target.tool
 		fmt.Println("Checking:", tool.Module, "...")
 
+		if tool.Hold {
+			fmt.Println(">>> Held, skip.", tool.HoldReason)
+			continue
+		}
+
+		if target.spec != c.spec && tool.ModuleVersion() == "" {
+			fmt.Println(">>> Follows the version of shared spec, skip.")
+			continue
+		}
+
+		// NOTE: version resolution does not run the tool, so tools of other platforms are upgraded too.
 		// FIXME(zhuravlev): remove all "is runtime supported" checks by checking it once at spec load.
 		rt, err := c.runtimes.Get(tool.Runtime)
 		if err != nil {
-			return fmt.Errorf("get runtime: %w", err)
+			return nil, fmt.Errorf("get runtime: %w", err)
 		}
 
-		module, haveUpdate, err := rt.GetLatest(ctx, tool.Module)
+		module, haveUpdate, err := rt.GetLatest(ctx, tool.Module, minAge)
 		if err != nil {
-			return fmt.Errorf("get latest module: %w", err)
+			return nil, fmt.Errorf("get latest module: %w", err)
 		}
 
 		if !haveUpdate {
@@ -446,37 +1027,96 @@ func (c *Workdir) Upgrade(ctx context.Context, filter func(structs.Tool) bool) e
 
 		fmt.Println(">>> Upgrade to:", module)
 
+		from := tool.Module
 		tool.Module = module
 
-		c.spec.Tools.UpsertTool(tool)
-		c.lock.Tools.UpsertTool(tool)
+		// NOTE: local lock is rebuilt from local spec by refreshLock.
+		target.spec.Tools.UpsertTool(tool)
+		if target.spec == c.spec {
+			c.lock.Tools.UpsertTool(tool)
+		}
+
+		upgraded = append(upgraded, Upgraded{Tool: tool, From: from})
 	}
 
 	resRemotes := make([]structs.RemoteSpec, 0, len(c.spec.Includes))
 	for _, inc := range c.spec.Includes {
-		remotes, err := remotes2.FetchRemote(ctx, c.fs, inc.Src, inc.Tags, nil)
+		remotes, err := c.fetcher.FetchRemote(ctx, inc.Src, inc.Tags, remotes2.FetchOpts{Revalidate: true})
 		if err != nil {
-			return fmt.Errorf("fetch remotes: %w", err)
+			return nil, fmt.Errorf("fetch remotes: %w", err)
 		}
 
 		resRemotes = append(resRemotes, remotes...)
 		for _, remote := range remotes {
-			for _, tool := range remote.Spec.Tools {
-				tool.Tags = append(tool.Tags, remote.Tags...)
-				c.lock.Tools.Add(tool)
-			}
+			c.printRemoteChanges(remote)
 		}
 	}
 
 	c.lock.Remotes = resRemotes
+	if err := c.refreshLock(); err != nil {
+		return nil, err
+	}
 
-	return nil
+	return upgraded, nil
+}
+
+// GetReleaseNotes returns release notes of versions between u.From (exclusive) and u.Tool (inclusive).
+func (c *Workdir) GetReleaseNotes(ctx context.Context, u Upgraded) ([]structs.ReleaseNote, error) {
+	rt, err := c.runtimes.GetInstall(ctx, u.Tool.Runtime)
+	if err != nil {
+		return nil, fmt.Errorf("get runtime: %w", err)
+	}
+
+	notes, err := rt.GetReleaseNotes(ctx, u.From, u.Tool.Module)
+	if err != nil {
+		return nil, fmt.Errorf("get release notes (%s): %w", u.Tool.Module, err)
+	}
+
+	return notes, nil
+}
+
+// GetChangelog returns release notes of versions between the current and the latest allowed version of tool.
+// The returned Upgraded has the same version when the tool is up-to-date.
+func (c *Workdir) GetChangelog(ctx context.Context, target string) (*Upgraded, []structs.ReleaseNote, error) {
+	ts, err := c.FindTool(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find tool: %w", err)
+	}
+
+	minAge, err := getMinReleaseAge(c.spec, c.profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rt, err := c.runtimes.GetInstall(ctx, ts.Tool.Runtime)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get runtime: %w", err)
+	}
+
+	latest, _, err := rt.GetLatest(ctx, ts.Tool.Module, minAge)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get latest module: %w", err)
+	}
+
+	u := Upgraded{Tool: ts.Tool, From: ts.Tool.Module}
+	u.Tool.Module = latest
+
+	if u.From == latest {
+		return &u, nil, nil
+	}
+
+	notes, err := c.GetReleaseNotes(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &u, notes, nil
 }
 
 // CopySource will add all tools from source.
 // Source can be a path to file or a http url or git repo.
 func (c *Workdir) CopySource(ctx context.Context, source string, tags []string) (int, error) {
-	specs, err := remotes2.FetchRemote(ctx, c.fs, source, tags, nil)
+	specs, err := c.fetcher.FetchRemote(ctx, source, tags, remotes2.FetchOpts{})
 	if err != nil {
 		return 0, fmt.Errorf("fetch spec: %w", err)
 	}
@@ -492,14 +1132,73 @@ func (c *Workdir) CopySource(ctx context.Context, source string, tags []string)
 		}
 	}
 
-	c.lock.FromSpec(c.spec)
+	if err := c.refreshLock(); err != nil {
+		return 0, err
+	}
 
 	return count, nil
 }
 
+// Outdated describes a tool that has a newer version.
+type Outdated struct {
+	Tool structs.Tool
+	// Latest is the newest published module.
+	Latest string
+	// Allowed is the newest module that satisfies min release age. Equals to tool module when nothing is allowed.
+	Allowed string
+}
+
+// IsCoolingDown returns true when the latest version is too young to be installed.
+func (o Outdated) IsCoolingDown() bool {
+	return o.Latest != o.Allowed
+}
+
+// GetOutdated returns tools (including local ones) that have newer versions.
+func (c *Workdir) GetOutdated(ctx context.Context) ([]Outdated, error) {
+	minAge, err := getMinReleaseAge(c.spec, c.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := c.tools()
+	res := make([]Outdated, 0, len(tools))
+	for _, tool := range tools {
+		rt, err := c.runtimes.GetInstall(ctx, tool.Runtime)
+		if err != nil {
+			return nil, fmt.Errorf("get runtime: %w", err)
+		}
+
+		latest, haveUpdate, err := rt.GetLatest(ctx, tool.Module, 0)
+		if err != nil {
+			return nil, fmt.Errorf("get latest module (%s): %w", tool.Module, err)
+		}
+
+		if !haveUpdate {
+			continue
+		}
+
+		allowed := latest
+		if minAge > 0 {
+			allowed, _, err = rt.GetLatest(ctx, tool.Module, minAge)
+			if err != nil {
+				return nil, fmt.Errorf("get latest allowed module (%s): %w", tool.Module, err)
+			}
+		}
+
+		res = append(res, Outdated{
+			Tool:    tool,
+			Latest:  latest,
+			Allowed: allowed,
+		})
+	}
+
+	return res, nil
+}
+
 func (c *Workdir) GetTools(ctx context.Context) ([]structs.ToolState, error) {
-	res := make([]structs.ToolState, 0, len(c.lock.Tools))
-	for _, tool := range c.lock.Tools {
+	tools := c.tools()
+	res := make([]structs.ToolState, 0, len(tools))
+	for _, tool := range tools {
 		mod, err := c.getModuleInfo(ctx, tool)
 		if err != nil {
 			return nil, fmt.Errorf("get module info: %w", err)
@@ -574,6 +1273,10 @@ func (c *Workdir) GetSystemInfo() (*SystemInfo, error) {
 		Envs: [][2]string{
 			{EnvCacheDir, os.Getenv(EnvCacheDir)},
 			{EnvSpecDir, os.Getenv(EnvSpecDir)},
+			{EnvMinReleaseAge, os.Getenv(EnvMinReleaseAge)},
+			{EnvOffline, os.Getenv(EnvOffline)},
+			{EnvIncludeTTL, os.Getenv(EnvIncludeTTL)},
+			{EnvProfile, os.Getenv(EnvProfile)},
 		},
 		Storage: Storage{
 			TotalBytes: size,
@@ -2,13 +2,24 @@ package workdir_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/kazhuravlev/optional"
 	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/prog"
 	"github.com/kazhuravlev/toolset/internal/workdir"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
 	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
@@ -97,3 +108,1153 @@ func TestCustomDir(t *testing.T) {
 		}, tree)
 	}
 }
+
+func TestDryRun(t *testing.T) {
+	setupTestEnv(t)
+
+	ctx := context.Background()
+	const dir = "/dir"
+
+	base := fsh.NewMemFS(nil)
+	require.NoError(t, workdir.Init(ctx, base, dir))
+
+	specBefore, err := afero.ReadFile(base, "/dir/.toolset.json")
+	require.NoError(t, err)
+
+	fs := fsh.NewOverlayFS(base)
+	wd, err := workdir.New(ctx, fs, dir)
+	require.NoError(t, err)
+
+	wd.SetDryRun(true)
+
+	_, err = wd.Ensure(ctx, "gh", "golangci/golangci-lint@v2.5.0", optional.Empty[string](), nil)
+	require.NoError(t, err)
+	require.NoError(t, wd.Sync(ctx, 1, nil))
+	require.NoError(t, wd.Save(ctx))
+
+	specAfter, err := afero.ReadFile(base, "/dir/.toolset.json")
+	require.NoError(t, err)
+	require.Equal(t, specBefore, specAfter)
+
+	changed, err := fs.ChangedFiles()
+	require.NoError(t, err)
+	require.Contains(t, changed, "/dir/.toolset.json")
+	require.Contains(t, changed, "/dir/.toolset.lock.json")
+
+	plan := wd.Plan()
+	require.Len(t, plan.Install, 1)
+	require.Equal(t, "golangci/golangci-lint@v2.5.0", plan.Install[0].Module)
+	require.Empty(t, plan.Remove)
+}
+
+func TestHold(t *testing.T) {
+	wd, fs := newTestWorkdir(t, "/dir", nil)
+
+	ctx := context.Background()
+
+	_, err := wd.Ensure(ctx, "gh", "golangci/golangci-lint@v2.5.0", optional.Empty[string](), nil)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, wd.SetHold("unknown-tool", true, ""), workdir.ErrToolNotFoundInSpec)
+	require.NoError(t, wd.SetHold("golangci-lint", true, "migrating configs"))
+
+	// Held tools are skipped without requests to remote.
+	upgraded, err := wd.Upgrade(ctx, func(structs.Tool) bool { return true })
+	require.NoError(t, err)
+	require.Empty(t, upgraded)
+
+	tools, err := wd.GetTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	require.True(t, tools[0].Tool.Hold)
+	require.Equal(t, "migrating configs", tools[0].Tool.HoldReason)
+
+	require.NoError(t, wd.SetHold("golangci-lint", false, ""))
+
+	tools, err = wd.GetTools(ctx)
+	require.NoError(t, err)
+	require.False(t, tools[0].Tool.Hold)
+	require.Empty(t, tools[0].Tool.HoldReason)
+
+	// Tools of local spec are held in local spec.
+	wd.SetLocal(true)
+	_, err = wd.Ensure(ctx, "gh", "go-delve/delve@v1.23.0", optional.Empty[string](), nil)
+	require.NoError(t, err)
+	require.NoError(t, wd.SetHold("delve", true, "debugging"))
+	require.NoError(t, wd.Save(ctx))
+
+	ts, err := wd.FindTool("delve")
+	require.NoError(t, err)
+	require.True(t, ts.Tool.Hold)
+
+	local, err := fsh.ReadJson[structs.Spec](ctx, fs, "/dir/.toolset.local.json")
+	require.NoError(t, err)
+	require.Len(t, local.Tools, 1)
+	require.True(t, local.Tools[0].Hold)
+	require.Equal(t, "debugging", local.Tools[0].HoldReason)
+
+	lock, err := fsh.ReadJson[structs.Lock](ctx, fs, "/dir/.toolset.lock.json")
+	require.NoError(t, err)
+	require.Len(t, lock.Tools, 1)
+}
+
+func TestWhy(t *testing.T) {
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{
+		"tools": [],
+		"includes": [{"src": "/a.json", "tags": ["company"], "exclude": ["gofumpt"], "override": [{"tool": "golangci-lint", "tags": ["lint"]}]}]
+	}`,
+		"/dir/.toolset.lock.json": `{
+		"tools": [
+			{"runtime": "gh", "module": "golangci/golangci-lint@v2.4.0", "alias": null, "tags": ["lint", "company"], "source": "/b.json"}
+		],
+		"remotes": [
+			{
+				"source": "/b.json",
+				"via": ["/a.json"],
+				"tags": ["company"],
+				"spec": {"tools": [
+					{"runtime": "gh", "module": "golangci/golangci-lint@v2.4.0", "alias": null, "tags": ["base"]}
+				]}
+			},
+			{
+				"source": "/a.json",
+				"tags": ["company"],
+				"spec": {
+					"tools": [
+						{"runtime": "gh", "module": "golangci/golangci-lint@v2.5.0", "alias": null, "tags": []},
+						{"runtime": "gh", "module": "mvdan/gofumpt@v0.7.0", "alias": null, "tags": []}
+					],
+					"includes": [{"src": "/b.json", "tags": []}]
+				}
+			}
+		]
+	}`,
+	})
+
+	why, err := wd.Why("golangci-lint")
+	require.NoError(t, err)
+	require.True(t, why.IsLocked)
+	require.Equal(t, "golangci/golangci-lint@v2.4.0", why.Locked.Module)
+	require.Len(t, why.Declarations, 2)
+
+	require.Equal(t, "/b.json", why.Declarations[0].Source)
+	require.Equal(t, workdir.DeclarationUsed, why.Declarations[0].Status)
+	require.Len(t, why.Declarations[0].Includes, 2)
+	require.Equal(t, "/a.json", why.Declarations[0].Includes[0].Src)
+	require.Equal(t, []string{"lint", "company"}, why.Declarations[0].Result.Tags)
+	// Own tags of the tool are replaced by rules. Tags of include are taken from the remote in lock.
+	require.Equal(t, []workdir.TagOrigin{
+		{Tag: "lint", Source: "/a.json", IsRule: true},
+		{Tag: "company", Source: "/b.json"},
+	}, why.Declarations[0].Tags)
+
+	require.Equal(t, "/a.json", why.Declarations[1].Source)
+	require.Equal(t, workdir.DeclarationLost, why.Declarations[1].Status)
+
+	why, err = wd.Why("mvdan/gofumpt")
+	require.NoError(t, err)
+	require.False(t, why.IsLocked)
+	require.Len(t, why.Declarations, 1)
+	require.Equal(t, workdir.DeclarationExcluded, why.Declarations[0].Status)
+	require.Equal(t, "/a.json", why.Declarations[0].RuleSource)
+
+	_, err = wd.Why("unknown")
+	require.ErrorIs(t, err, workdir.ErrToolNotFoundInSpec)
+}
+
+func TestIncludes(t *testing.T) {
+	wd, fs := newTestWorkdir(t, "/dir", map[string]string{
+		"/a.json": `{"tools": [
+			{"runtime": "gh", "module": "golangci/golangci-lint@v2.4.0", "alias": null, "tags": []},
+			{"runtime": "gh", "module": "mvdan/gofumpt@v0.7.0", "alias": null, "tags": []}
+		]}`,
+		"/b.json": `{"tools": [
+			{"runtime": "gh", "module": "golangci/golangci-lint@v2.5.0", "alias": null, "tags": []}
+		]}`,
+	})
+
+	ctx := context.Background()
+
+	_, err := wd.AddInclude(ctx, "/a.json", nil)
+	require.NoError(t, err)
+	_, err = wd.AddInclude(ctx, "/b.json", nil)
+	require.NoError(t, err)
+
+	includes := wd.ListIncludes()
+	require.Len(t, includes, 2)
+	require.Equal(t, "/a.json", includes[0].Include.Src)
+	require.Len(t, includes[0].Tools, 2)
+	require.Len(t, includes[1].Tools, 0, "golangci-lint is provided by the first include")
+
+	// Change the include and refresh it.
+	require.NoError(t, afero.WriteFile(fs, "/a.json", []byte(`{"tools": [
+		{"runtime": "gh", "module": "mvdan/gofumpt@v0.8.0", "alias": null, "tags": []},
+		{"runtime": "gh", "module": "golang/mock@v1.6.0", "alias": null, "tags": []}
+	]}`), 0o644))
+
+	diff, err := wd.RefreshInclude(ctx, "/a.json")
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang/mock@v1.6.0"}, modules(diff.Added))
+	require.Empty(t, diff.Removed)
+	require.Len(t, diff.Changed, 2)
+	require.Equal(t, "mvdan/gofumpt@v0.8.0", diff.Changed[0].To.Module)
+	require.Equal(t, "golangci/golangci-lint@v2.5.0", diff.Changed[1].To.Module)
+
+	diff, err = wd.RemoveInclude("/b.json")
+	require.NoError(t, err)
+	require.Equal(t, []string{"golangci/golangci-lint@v2.5.0"}, modules(diff.Removed))
+	require.Len(t, wd.ListIncludes(), 1)
+
+	_, err = wd.RemoveInclude("/b.json")
+	require.ErrorIs(t, err, workdir.ErrIncludeNotFound)
+	_, err = wd.RefreshInclude(ctx, "/b.json")
+	require.ErrorIs(t, err, workdir.ErrIncludeNotFound)
+}
+
+func modules(tools structs.Tools) []string {
+	res := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		res = append(res, tool.Module)
+	}
+
+	return res
+}
+
+func TestSpecFormats(t *testing.T) {
+	const dir = "/dir"
+	const spec = `{
+	// Project tools.
+	"tools": [],
+	"includes": [],
+}
+`
+
+	wd, fs := newTestWorkdir(t, "/dir/sub", map[string]string{
+		"/dir/.toolset.jsonc":     spec,
+		"/dir/.toolset.lock.json": `{"tools": [], "remotes": []}`,
+	})
+
+	ctx := context.Background()
+	require.ErrorContains(t, workdir.Init(ctx, fs, dir), "spec already exists")
+
+	// Unchanged spec is not rewritten.
+	require.NoError(t, wd.Save(ctx))
+	bb, err := afero.ReadFile(fs, "/dir/.toolset.jsonc")
+	require.NoError(t, err)
+	require.Equal(t, spec, string(bb))
+
+	_, err = wd.Ensure(ctx, "gh", "golangci/golangci-lint@v2.5.0", optional.Empty[string](), nil)
+	require.NoError(t, err)
+
+	// Changed spec keeps comments.
+	require.NoError(t, wd.Save(ctx))
+	bb, err = afero.ReadFile(fs, "/dir/.toolset.jsonc")
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "\t// Project tools.\n\t\"tools\": [\n")
+	require.Contains(t, string(bb), "golangci/golangci-lint@v2.5.0")
+
+	filename, err := wd.Convert(ctx, "yaml")
+	require.NoError(t, err)
+	require.Equal(t, "/dir/.toolset.yaml", filename)
+	require.NoError(t, wd.Save(ctx))
+	require.False(t, fsh.IsExists(fs, "/dir/.toolset.jsonc"))
+
+	_, err = wd.Convert(ctx, "yaml")
+	require.ErrorContains(t, err, "already in yaml format")
+
+	wd, err = workdir.New(ctx, fs, dir)
+	require.NoError(t, err)
+
+	tools, err := wd.GetTools(ctx)
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	require.Equal(t, "golangci/golangci-lint@v2.5.0", tools[0].Tool.Module)
+	require.True(t, workdir.IsProjectFile(filename))
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		wd, _ := newTestWorkdir(t, "/dir", nil)
+
+		_, err := wd.Ensure(ctx, "gh", "golangci/golangci-lint@v2.5.0", optional.Empty[string](), nil)
+		require.NoError(t, err)
+		require.NoError(t, wd.Save(ctx))
+
+		require.Empty(t, wd.Validate())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+			"/dir/.toolset.json": `{
+	"version": 1,
+	"tools": [
+		{"runtime": "golang", "module": "mvdan.cc/gofumpt@v0.7.0", "alias": null, "tags": []},
+		{"runtime": "gh", "module": "golangci/golangci-lint@latest", "alias": null, "tags": []},
+		{"runtime": "gh", "module": "golangci/golangci-lint@v2.5.0", "alias": "lint", "tags": []},
+		{"runtime": "go", "module": "example.com/lint@v1.0.0", "alias": null, "tags": []}
+	],
+	"includes": [],
+	"minReleaseAge": "soon",
+	"modul": "typo"
+}`,
+			"/dir/.toolset.lock.json": `{
+	"version": 1,
+	"tools": [
+		{"runtime": "gh", "module": "golangci/golangci-lint@v2.5.0", "alias": "lint", "tags": []},
+		{"runtime": "go", "module": "example.com/lint@v1.0.0", "alias": null, "tags": []}
+	],
+	"remotes": []
+}`,
+		})
+
+		problems := wd.Validate()
+		msgs := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			msgs = append(msgs, problem.String())
+		}
+
+		require.Equal(t, []string{
+			`.toolset.json: json: unknown field "modul"`,
+			`.toolset.json: minReleaseAge: invalid duration: time: invalid duration "soon"`,
+			".toolset.json: tools[0]: unsupported runtime: golang",
+			".toolset.json: tools[1]: non-semver versions is not supported",
+			".toolset.json: tools[2]: duplicates tools[1]",
+			".toolset.lock.json: name (lint) is used by several tools: gh:golangci/golangci-lint@v2.5.0, go:example.com/lint@v1.0.0",
+			".toolset.lock.json: tool (mvdan.cc/gofumpt@v0.7.0) is not locked. Run `toolset sync`",
+			".toolset.lock.json: tool (golangci/golangci-lint@latest) is locked as golangci/golangci-lint@v2.5.0. Run `toolset sync`",
+		}, msgs)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	const dir = "/dir"
+	const spec = `{"dir": "./bin/tools", "tools": [], "includes": []}`
+
+	wd, fs := newTestWorkdir(t, dir, map[string]string{
+		"/dir/.toolset.json":      spec,
+		"/dir/.toolset.lock.json": `{"tools": [], "remotes": []}`,
+	})
+
+	ctx := context.Background()
+
+	// Spec is migrated in memory only.
+	require.NoError(t, wd.Save(ctx))
+	bb, err := afero.ReadFile(fs, "/dir/.toolset.json")
+	require.NoError(t, err)
+	require.Equal(t, spec, string(bb))
+
+	migrated := wd.Migrate()
+	require.Len(t, migrated.Spec, 2)
+	require.Len(t, migrated.Lock, 1)
+	require.NoError(t, wd.Save(ctx))
+
+	bb, err = afero.ReadFile(fs, "/dir/.toolset.json")
+	require.NoError(t, err)
+	require.Equal(t, "{\n\t\"version\": 1,\n\t\"tools\": [],\n\t\"includes\": []\n}\n", string(bb))
+
+	wd, err = workdir.New(ctx, fs, dir)
+	require.NoError(t, err)
+	require.True(t, wd.Migrate().IsEmpty())
+
+	require.NoError(t, afero.WriteFile(fs, "/dir/.toolset.json", []byte(`{"version": 100}`), 0o644))
+	_, err = workdir.New(ctx, fs, dir)
+	require.ErrorIs(t, err, migrations.ErrUnsupportedVersion)
+}
+
+func TestRunOpts(t *testing.T) {
+	t.Setenv("TEST_CACHE", "/cache")
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json":      `{"version": 1, "tools": [], "includes": []}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": []}`,
+	})
+
+	tool := structs.Tool{
+		Runtime: "go",
+		Module:  "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0",
+		Env: map[string]string{
+			"GOFLAGS":       "-mod=mod",
+			"BUF_CACHE_DIR": "${TEST_CACHE}/buf",
+		},
+		Args: structs.ToolArgs{
+			Prepend: []string{"--config", "$PROJECT_ROOT/.golangci.yml"},
+			Append:  []string{"--verbose"},
+		},
+	}
+
+	opts := wd.RunOpts(tool, []string{"run", "./..."})
+	require.Equal(t, structs.RunOpts{
+		Env:  []string{"BUF_CACHE_DIR=/cache/buf", "GOFLAGS=-mod=mod"},
+		Args: []string{"--config", "/dir/.golangci.yml", "run", "./...", "--verbose"},
+	}, opts)
+
+	// User's args are not expanded.
+	opts = wd.RunOpts(structs.Tool{}, []string{"$PROJECT_ROOT"})
+	require.Equal(t, []string{"$PROJECT_ROOT"}, opts.Args)
+}
+
+func TestSyncSkipsUnsupportedPlatforms(t *testing.T) {
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{
+	"version": 1,
+	"tools": [
+		{"runtime": "go", "module": "github.com/example/profiler@v1.0.0", "platforms": ["plan9/mips"]},
+		{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0"}
+	],
+	"includes": []
+}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": []}`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(map[string][]string{
+		"github.com/example/profiler": {"v1.0.0", "v1.1.0"},
+	})
+	wd.SetRuntime(rt)
+
+	require.NoError(t, wd.Sync(ctx, 1, nil))
+	require.Equal(t, []string{"golang.org/x/tools/cmd/stringer@v0.21.0"}, rt.Installed())
+
+	err := wd.RunTool(ctx, "profiler")
+	require.ErrorIs(t, err, workdir.ErrUnsupportedPlatform)
+
+	// Upgrade does not run the tool, so it upgrades tools of other platforms too.
+	upgraded, err := wd.Upgrade(ctx, func(structs.Tool) bool { return true })
+	require.NoError(t, err)
+	require.Len(t, upgraded, 1)
+	require.Equal(t, "github.com/example/profiler@v1.1.0", upgraded[0].Tool.Module)
+	require.Equal(t, []string{"golang.org/x/tools/cmd/stringer@v0.21.0"}, rt.Installed())
+}
+
+func TestScripts(t *testing.T) {
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{
+	"version": 1,
+	"tools": [],
+	"includes": [],
+	"scripts": {
+		"ci": {"description": "all checks", "steps": [{"task": "lint"}, {"task": "loop"}]},
+		"lint": {"parallel": true, "steps": [{"tool": "golangci-lint", "args": ["run"]}, {}]},
+		"loop": {"steps": [{"task": "loop2"}]},
+		"loop2": {"steps": [{"task": "loop"}, {"task": "missing"}]}
+	}
+}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": []}`,
+	})
+
+	ctx := context.Background()
+
+	scripts := wd.ListScripts()
+	require.Len(t, scripts, 4)
+	require.Equal(t, "ci", scripts[0].Name)
+	require.Equal(t, "all checks", scripts[0].Description)
+
+	require.ErrorIs(t, wd.RunScript(ctx, "unknown"), workdir.ErrScriptNotFound)
+	require.ErrorContains(t, wd.RunScript(ctx, "loop"), "loop -> loop2 -> loop")
+
+	var problems []string
+	for _, p := range wd.Validate() {
+		problems = append(problems, p.String())
+	}
+
+	require.Equal(t, []string{
+		".toolset.json: scripts.lint.steps[0]: unknown tool (golangci-lint)",
+		".toolset.json: scripts.lint.steps[1]: expected one of tool or task",
+		".toolset.json: scripts.loop: script calls itself: loop -> loop2 -> loop",
+		".toolset.json: scripts.loop2.steps[1]: unknown task (missing)",
+		".toolset.json: scripts.loop2: script calls itself: loop2 -> loop -> loop2",
+	}, problems)
+}
+
+func TestDryRunScript(t *testing.T) {
+	wd, fs := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{
+	"version": 1,
+	"tools": [
+		{"runtime": "go", "module": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0"},
+		{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0"}
+	],
+	"includes": [],
+	"scripts": {
+		"ci": {"parallel": true, "steps": [
+			{"tool": "golangci-lint", "args": ["run"]},
+			{"tool": "stringer", "args": ["-type=Kind"]},
+			{"tool": "golangci-lint", "args": ["fmt"]}
+		]}
+	}
+}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0"},
+	{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0"}
+]}`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(nil)
+	wd.SetRuntime(rt)
+	require.NoError(t, rt.Install(ctx, "golang.org/x/tools/cmd/stringer@v0.21.0"))
+
+	info, err := wd.GetSystemInfo()
+	require.NoError(t, err)
+	stats, err := afero.ReadFile(fs, info.Locations.StatsFile)
+	require.NoError(t, err)
+
+	wd.SetDryRun(true)
+	require.NoError(t, wd.RunScript(ctx, "ci"))
+	require.NoError(t, wd.RunTool(ctx, "golangci-lint", "version"))
+
+	// Nothing is installed, run or saved.
+	require.Equal(t, []string{"golang.org/x/tools/cmd/stringer@v0.21.0"}, rt.Installed())
+	bb, err := afero.ReadFile(fs, info.Locations.StatsFile)
+	require.NoError(t, err)
+	require.Equal(t, string(stats), string(bb))
+
+	plan := wd.Plan()
+	require.Equal(t, []string{"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0"}, modules(plan.Install))
+
+	var runs []string
+	for _, run := range plan.Run {
+		runs = append(runs, run.Tool.ModuleName()+" "+strings.Join(run.Args, " "))
+	}
+
+	require.Equal(t, []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint run",
+		"golang.org/x/tools/cmd/stringer -type=Kind",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint fmt",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint version",
+	}, runs)
+}
+
+func TestRunScript(t *testing.T) {
+	setupTestEnv(t)
+
+	// Real fs is used, because steps run a real binary. The tool binary is a shell script that logs its calls.
+	dir := t.TempDir()
+	t.Setenv(workdir.EnvCacheDir, filepath.Join(dir, "cache"))
+
+	logFile := filepath.Join(dir, "calls.log")
+	t.Setenv("TEST_LOG", logFile)
+
+	ctx := context.Background()
+	fs := fsh.NewRealFS()
+	project := filepath.Join(dir, "project")
+	require.NoError(t, fs.MkdirAll(filepath.Join(project, "sub"), fsh.DefaultDirPerm))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(project, ".toolset.json"), []byte(`{
+	"version": 1,
+	"tools": [{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0", "env": {"MSG": "tool"}}],
+	"includes": [],
+	"scripts": {
+		"seq": {"steps": [
+			{"tool": "stringer", "args": ["one"]},
+			{"tool": "stringer", "args": ["two"], "env": {"MSG": "step"}, "dir": "sub"},
+			{"task": "nested"}
+		]},
+		"nested": {"steps": [{"tool": "stringer", "args": ["three"]}]},
+		"parallel": {"parallel": true, "steps": [
+			{"tool": "stringer", "args": ["wait"]},
+			{"tool": "stringer", "args": ["ready"]}
+		]},
+		"fail": {"steps": [
+			{"tool": "stringer", "args": ["exit", "5"]},
+			{"tool": "stringer", "args": ["never"]}
+		]}
+	}
+}`), 0o644))
+	require.NoError(t, afero.WriteFile(fs, filepath.Join(project, ".toolset.lock.json"), []byte(`{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0", "env": {"MSG": "tool"}}
+]}`), 0o644))
+
+	wd, err := workdir.New(ctx, fs, project)
+	require.NoError(t, err)
+
+	ts, err := wd.FindTool("stringer")
+	require.NoError(t, err)
+	require.NoError(t, fs.MkdirAll(ts.Module.BinDir, fsh.DefaultDirPerm))
+	require.NoError(t, afero.WriteFile(fs, ts.Module.BinPath, []byte(`#!/bin/sh
+case "$1" in
+wait)
+	# Finishes only when the next step runs at the same time.
+	i=0
+	while [ ! -f "$TEST_LOG.ready" ]; do
+		i=$((i+1))
+		[ "$i" -gt 100 ] && exit 9
+		sleep 0.05
+	done
+	;;
+ready) touch "$TEST_LOG.ready" ;;
+exit) exit "$2" ;;
+esac
+echo "$1 $MSG $(pwd -P)" >> "$TEST_LOG"
+`), 0o755))
+
+	readCalls := func() []string {
+		t.Helper()
+
+		bb, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(logFile))
+
+		return strings.Split(strings.TrimSpace(string(bb)), "\n")
+	}
+
+	root, err := filepath.EvalSymlinks(project)
+	require.NoError(t, err)
+
+	t.Run("sequential", func(t *testing.T) {
+		require.NoError(t, wd.RunScript(ctx, "seq"))
+		require.Equal(t, []string{
+			"one tool " + root,
+			"two step " + filepath.Join(root, "sub"),
+			"three tool " + root,
+		}, readCalls())
+	})
+
+	t.Run("parallel", func(t *testing.T) {
+		require.NoError(t, wd.RunScript(ctx, "parallel"))
+
+		calls := readCalls()
+		slices.Sort(calls)
+		require.Equal(t, []string{"ready tool " + root, "wait tool " + root}, calls)
+	})
+
+	t.Run("exit_code", func(t *testing.T) {
+		err := wd.RunScript(ctx, "fail")
+
+		var errRun structs.RunError
+		require.ErrorAs(t, err, &errRun)
+		require.Equal(t, 5, errRun.ExitCode)
+		require.NoFileExists(t, logFile, "steps after the failed one must not run")
+	})
+}
+
+func TestProfiles(t *testing.T) {
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{
+	"version": 1,
+	"tools": [],
+	"includes": [],
+	"profiles": {
+		"ci": {"tags": ["ci"], "parallel": 8, "offline": true, "env": {"GOFLAGS": "-mod=readonly"}},
+		"broken": {"minReleaseAge": "soon", "parallel": -1}
+	}
+}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": []}`,
+	})
+
+	require.ErrorIs(t, wd.SetProfile("unknown"), workdir.ErrProfileNotFound)
+	require.NoError(t, wd.SetProfile("ci"))
+
+	name, profile := wd.Profile()
+	require.Equal(t, "ci", name)
+	require.Equal(t, 8, profile.Parallel)
+
+	tool := structs.Tool{Env: map[string]string{"GOFLAGS": "-mod=mod"}}
+	require.Equal(t, []string{"GOFLAGS=-mod=mod", "GOFLAGS=-mod=readonly"}, wd.RunOpts(tool, nil).Env)
+
+	var problems []string
+	for _, p := range wd.Validate() {
+		problems = append(problems, p.String())
+	}
+
+	require.Equal(t, []string{
+		`.toolset.json: profiles.broken.minReleaseAge: invalid duration: time: invalid duration "soon"`,
+		".toolset.json: profiles.broken.parallel: must not be negative",
+		".toolset.json: profiles.ci.tags: tag (ci) is not used by any tool",
+	}, problems)
+}
+
+func TestProfileLockChecks(t *testing.T) {
+	const spec = `{
+	"version": 1,
+	"tools": [{"runtime": "go", "module": "github.com/shared/linter@%s"}],
+	"includes": [{"src": "/a.json", "tags": []}, {"src": "/b.json", "tags": []}],
+	"profiles": {
+		"strict": {"conflictPolicy": "error"},
+		"frozen": {"frozen": true},
+		"broken": {"conflictPolicy": "random"}
+	}
+}`
+
+	wd, fs := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": fmt.Sprintf(spec, "v1.0.0"),
+		"/dir/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": [
+	{"source": "/a.json", "tags": [], "spec": {"version": 1, "includes": [], "tools": [
+		{"runtime": "go", "module": "github.com/shared/gen@v1.0.0"}
+	]}},
+	{"source": "/b.json", "tags": [], "spec": {"version": 1, "includes": [], "tools": [
+		{"runtime": "go", "module": "github.com/shared/gen@v2.0.0"}
+	]}}
+]}`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(nil)
+	wd.SetRuntime(rt)
+
+	var problems []string
+	for _, p := range wd.Validate() {
+		problems = append(problems, p.String())
+	}
+
+	require.Contains(t, problems, ".toolset.json: profiles.broken.conflictPolicy: unknown conflict policy (random): should be one of first-wins, highest-version-wins, error")
+
+	// Frozen profile does not accept a lock that was not synced.
+	require.NoError(t, wd.SetProfile("frozen"))
+	require.ErrorIs(t, wd.Sync(ctx, 1, nil), workdir.ErrLockOutdated)
+	require.Empty(t, rt.Installed())
+
+	require.NoError(t, wd.SetProfile(""))
+	require.NoError(t, wd.Sync(ctx, 1, nil))
+	require.NoError(t, wd.Save(ctx))
+	require.Equal(t, []string{"github.com/shared/gen@v1.0.0", "github.com/shared/linter@v1.0.0"}, rt.Installed())
+
+	open := func(profile string) *workdir.Workdir {
+		wd, err := workdir.New(ctx, fs, "/dir")
+		require.NoError(t, err)
+		require.NoError(t, wd.SetProfile(profile))
+		wd.SetRuntime(rt)
+
+		return wd
+	}
+
+	require.ErrorIs(t, open("strict").Sync(ctx, 1, nil), structs.ErrConflict)
+	require.NoError(t, open("frozen").Sync(ctx, 1, nil))
+
+	require.NoError(t, afero.WriteFile(fs, "/dir/.toolset.json", []byte(fmt.Sprintf(spec, "v1.1.0")), 0o644))
+	err := open("frozen").Sync(ctx, 1, nil)
+	require.ErrorIs(t, err, workdir.ErrLockOutdated)
+	require.ErrorContains(t, err, "tool (github.com/shared/linter@v1.1.0) is locked as github.com/shared/linter@v1.0.0")
+	require.NotContains(t, rt.Installed(), "github.com/shared/linter@v1.1.0")
+
+	require.NoError(t, open("").Sync(ctx, 1, nil))
+	require.Contains(t, rt.Installed(), "github.com/shared/linter@v1.1.0")
+}
+
+func TestWorkspace(t *testing.T) {
+	root, fs := newTestWorkdir(t, "/ws", map[string]string{
+		"/ws/.toolset.json": `{"version": 1, "includes": [], "workspace": {"members": ["services/*"]}, "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0"},
+	{"runtime": "go", "module": "github.com/shared/gen@v1.0.0"}
+]}`,
+		"/ws/.toolset.lock.json": `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0"},
+	{"runtime": "go", "module": "github.com/shared/gen@v1.0.0"}
+]}`,
+		"/ws/services/api/.toolset.json": `{"version": 1, "includes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/gen@v2.0.0"}
+]}`,
+		"/ws/services/api/.toolset.lock.json": `{"version": 1, "tools": [], "remotes": []}`,
+		"/ws/services/web/README.md":          "not a member",
+		"/ws/tools/.toolset.json":             `{"version": 1, "tools": [], "includes": []}`,
+		"/ws/tools/.toolset.lock.json":        `{"version": 1, "tools": [], "remotes": []}`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(nil)
+	root.SetRuntime(rt)
+
+	members, err := root.Members(ctx)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, "/ws/services/api", members[0].ProjectRootDir())
+
+	member, err := workdir.New(ctx, fs, "/ws/services/api")
+	require.NoError(t, err)
+	member.SetRuntime(rt)
+
+	wsRoot, err := member.WorkspaceRoot(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/ws", wsRoot.ProjectRootDir())
+
+	// Not a member. It does not inherit tools of root.
+	other, err := workdir.New(ctx, fs, "/ws/tools")
+	require.NoError(t, err)
+	otherRoot, err := other.WorkspaceRoot(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "/ws/tools", otherRoot.ProjectRootDir())
+
+	require.NoError(t, member.Sync(ctx, 1, nil))
+	require.NoError(t, member.Save(ctx))
+	require.Empty(t, member.Validate())
+	// Inherited tools are installed by member sync.
+	require.Equal(t, []string{"github.com/shared/gen@v2.0.0", "github.com/shared/linter@v1.0.0"}, rt.Installed())
+
+	lock, err := fsh.ReadJson[structs.Lock](ctx, fs, "/ws/services/api/.toolset.lock.json")
+	require.NoError(t, err)
+	require.Len(t, lock.Tools, 2)
+	// Member spec has a priority.
+	require.Equal(t, "github.com/shared/gen@v2.0.0", lock.Tools[0].Module)
+	require.Equal(t, "", lock.Tools[0].Source)
+	require.Equal(t, "github.com/shared/linter@v1.0.0", lock.Tools[1].Module)
+	require.Equal(t, "../../.toolset.json", lock.Tools[1].Source)
+
+	// Sync of the whole workspace installs each module once.
+	require.NoError(t, root.Sync(ctx, 1, nil))
+	for _, member := range members {
+		require.NoError(t, member.Sync(ctx, 1, nil))
+	}
+
+	require.Equal(t, []string{
+		"github.com/shared/gen@v1.0.0",
+		"github.com/shared/gen@v2.0.0",
+		"github.com/shared/linter@v1.0.0",
+	}, rt.Installed())
+}
+
+func TestLocalSpec(t *testing.T) {
+	const lock = `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0", "alias": null, "tags": []}
+]}`
+
+	wd, fs := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{"version": 1, "includes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0", "alias": null, "tags": []}
+]}`,
+		"/dir/.toolset.lock.json": lock,
+		"/dir/.toolset.local.yaml": `
+version: 1
+tools:
+  - runtime: go
+    module: github.com/shared/linter@v2.0.0
+  - runtime: go
+    module: github.com/go-delve/delve/cmd/dlv@v1.23.0
+`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(map[string][]string{
+		"github.com/go-delve/delve/cmd/dlv": {"v1.23.0", "v1.24.0"},
+	})
+	wd.SetRuntime(rt)
+
+	require.NoError(t, wd.Sync(ctx, 1, nil))
+	require.NoError(t, wd.Save(ctx))
+	require.Empty(t, wd.Validate())
+
+	// Shared lock is not changed.
+	bb, err := afero.ReadFile(fs, "/dir/.toolset.lock.json")
+	require.NoError(t, err)
+	require.JSONEq(t, lock, string(bb))
+
+	localLock, err := fsh.ReadJson[structs.Lock](ctx, fs, "/dir/.toolset.local.lock.json")
+	require.NoError(t, err)
+	require.Len(t, localLock.Tools, 2)
+	require.Equal(t, "github.com/shared/linter@v2.0.0", localLock.Tools[0].Module)
+	require.Equal(t, ".toolset.local.yaml", localLock.Tools[0].Source)
+	require.Equal(t, "github.com/go-delve/delve/cmd/dlv@v1.23.0", localLock.Tools[1].Module)
+
+	require.True(t, workdir.IsProjectFile("/dir/.toolset.local.lock.json"))
+
+	// Local tool overrides the shared one.
+	require.Equal(t, []string{"github.com/go-delve/delve/cmd/dlv@v1.23.0", "github.com/shared/linter@v2.0.0"}, rt.Installed())
+
+	// Local tools are upgraded in the local spec.
+	upgraded, err := wd.Upgrade(ctx, func(tool structs.Tool) bool { return tool.ModuleName() == "github.com/go-delve/delve/cmd/dlv" })
+	require.NoError(t, err)
+	require.Len(t, upgraded, 1)
+	require.Equal(t, "github.com/go-delve/delve/cmd/dlv@v1.24.0", upgraded[0].Tool.Module)
+	require.NoError(t, wd.Save(ctx))
+
+	bb, err = afero.ReadFile(fs, "/dir/.toolset.lock.json")
+	require.NoError(t, err)
+	require.JSONEq(t, lock, string(bb))
+
+	bb, err = afero.ReadFile(fs, "/dir/.toolset.local.yaml")
+	require.NoError(t, err)
+	require.Contains(t, string(bb), "module: github.com/go-delve/delve/cmd/dlv@v1.24.0")
+
+	localLock, err = fsh.ReadJson[structs.Lock](ctx, fs, "/dir/.toolset.local.lock.json")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/go-delve/delve/cmd/dlv@v1.24.0", localLock.Tools[1].Module)
+}
+
+func TestLocalOverride(t *testing.T) {
+	const lock = `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0", "alias": "lint", "tags": ["linters"], "env": {"GOFLAGS": "-mod=mod"}},
+	{"runtime": "go", "module": "github.com/shared/gen@v1.0.0", "alias": null, "tags": ["codegen"]}
+]}`
+
+	wd, fs := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{"version": 1, "includes": [], "tools": [
+	{"runtime": "go", "module": "github.com/shared/linter@v1.0.0", "alias": "lint", "tags": ["linters"], "env": {"GOFLAGS": "-mod=mod"}},
+	{"runtime": "go", "module": "github.com/shared/gen@v1.0.0", "alias": null, "tags": ["codegen"]}
+]}`,
+		"/dir/.toolset.lock.json": lock,
+		"/dir/.toolset.local.yaml": `
+version: 1
+tools:
+  - runtime: go
+    module: github.com/shared/linter@v1.1.0
+  - runtime: go
+    module: github.com/shared/gen
+    alias: g
+`,
+	})
+
+	ctx := context.Background()
+	rt := newFakeRuntime(map[string][]string{
+		"github.com/shared/gen": {"v1.0.0", "v2.0.0"},
+	})
+	wd.SetRuntime(rt)
+
+	require.NoError(t, wd.Sync(ctx, 1, nil))
+	require.NoError(t, wd.Save(ctx))
+	require.Empty(t, wd.Validate())
+	require.Equal(t, []string{"github.com/shared/gen@v1.0.0", "github.com/shared/linter@v1.1.0"}, rt.Installed())
+
+	bb, err := afero.ReadFile(fs, "/dir/.toolset.lock.json")
+	require.NoError(t, err)
+	require.JSONEq(t, lock, string(bb))
+
+	// Fields that are not set by local entries are taken from the shared lock.
+	localLock, err := fsh.ReadJson[structs.Lock](ctx, fs, "/dir/.toolset.local.lock.json")
+	require.NoError(t, err)
+	require.Equal(t, structs.Tools{
+		{
+			Runtime: "go",
+			Module:  "github.com/shared/linter@v1.1.0",
+			Alias:   optional.New("lint"),
+			Tags:    []string{"linters"},
+			Env:     map[string]string{"GOFLAGS": "-mod=mod"},
+			Source:  ".toolset.local.yaml",
+		},
+		{
+			Runtime: "go",
+			Module:  "github.com/shared/gen@v1.0.0",
+			Alias:   optional.New("g"),
+			Tags:    []string{"codegen"},
+			Source:  ".toolset.local.yaml",
+		},
+	}, localLock.Tools)
+
+	// Entry without version follows the shared spec.
+	upgraded, err := wd.Upgrade(ctx, func(tool structs.Tool) bool { return tool.ModuleName() == "github.com/shared/gen" })
+	require.NoError(t, err)
+	require.Len(t, upgraded, 1)
+	require.Empty(t, upgraded[0].Tool.Source)
+
+	ts, err := wd.FindTool("g")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/shared/gen@v2.0.0", ts.Tool.Module)
+}
+
+func TestShims(t *testing.T) {
+	wd, fs := newTestWorkdir(t, "/dir/sub", map[string]string{
+		"/dir/.toolset.json": `{"version": 1, "tools": [], "includes": []}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0", "alias": "lint"},
+	{"runtime": "go", "module": "github.com/example/profiler@v1.0.0", "platforms": ["plan9"]}
+]}`,
+		"/dir/.toolset/bin/stale": "#!/bin/sh\n# Generated by toolset. DO NOT EDIT.\n",
+		"/dir/.toolset/bin/mine":  "#!/bin/sh\necho hello\n",
+	})
+
+	ctx := context.Background()
+	require.True(t, wd.HasShims())
+
+	names, err := wd.WriteShims(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"golangci-lint", "lint"}, names)
+
+	bb, err := afero.ReadFile(fs, "/dir/.toolset/bin/lint")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\n# Generated by toolset. DO NOT EDIT.\nexec \"${TOOLSET_BIN:-toolset}\" --project '/dir' run 'lint' \"$@\"\n", string(bb))
+
+	require.False(t, fsh.IsExists(fs, "/dir/.toolset/bin/stale"))
+	require.True(t, fsh.IsExists(fs, "/dir/.toolset/bin/mine"))
+	require.False(t, fsh.IsExists(fs, "/dir/.toolset/bin/profiler"))
+}
+
+func TestActivation(t *testing.T) {
+	setupTestEnv(t)
+
+	fs := fsh.NewMemFS(map[string]string{
+		"/dir/.toolset.json": `{"version": 1, "tools": [], "includes": []}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0", "alias": "lint"}
+]}`,
+		"/other/README.md": "not a project",
+	})
+
+	tree, err := fs.GetTree("/")
+	require.NoError(t, err)
+
+	activation, err := workdir.GetActivation(fs, "/dir/sub")
+	require.NoError(t, err)
+	require.Equal(t, &workdir.Activation{ShimsDir: "/dir/.toolset/bin", HasShims: false}, activation)
+
+	_, err = workdir.GetActivation(fs, "/other")
+	require.ErrorIs(t, err, workdir.ErrSpecNotFound)
+
+	// Nothing is written, even the cache dir.
+	after, err := fs.GetTree("/")
+	require.NoError(t, err)
+	require.Equal(t, tree, after)
+
+	require.NoError(t, fs.MkdirAll("/dir/.toolset/bin", 0o755))
+
+	activation, err = workdir.GetActivation(fs, "/dir")
+	require.NoError(t, err)
+	require.True(t, activation.HasShims)
+}
+
+func TestExec(t *testing.T) {
+	wd, _ := newTestWorkdir(t, "/dir", map[string]string{
+		"/dir/.toolset.json": `{"version": 1, "tools": [], "includes": []}`,
+		"/dir/.toolset.lock.json": `{"version": 1, "remotes": [], "tools": [
+	{"runtime": "go", "module": "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.59.0", "tags": ["linters"]},
+	{"runtime": "go", "module": "golang.org/x/tools/cmd/stringer@v0.21.0", "tags": ["codegen"]}
+]}`,
+	})
+
+	ctx := context.Background()
+
+	ts, err := wd.FindTool("stringer")
+	require.NoError(t, err)
+
+	t.Run("tools_first_on_path", func(t *testing.T) {
+		script := fmt.Sprintf(`case "$PATH" in %q:*) exit 0;; esac; exit 4`, ts.Module.BinDir)
+		require.NoError(t, wd.Exec(ctx, []string{"codegen"}, "sh", "-c", script))
+	})
+
+	t.Run("exit_code", func(t *testing.T) {
+		err := wd.Exec(ctx, nil, "sh", "-c", "exit 3")
+
+		var errRun structs.RunError
+		require.ErrorAs(t, err, &errRun)
+		require.Equal(t, 3, errRun.ExitCode)
+	})
+}
+
+// newTestWorkdir creates a workdir for dir on in-memory fs with files. The project is initialized by workdir.Init
+// when files have no spec for it.
+func newTestWorkdir(t *testing.T, dir string, files map[string]string) (*workdir.Workdir, *fsh.MemFS) {
+	t.Helper()
+
+	setupTestEnv(t)
+
+	ctx := context.Background()
+	fs := fsh.NewMemFS(files)
+	wd, err := workdir.New(ctx, fs, dir)
+	if errors.Is(err, workdir.ErrSpecNotFound) {
+		require.NoError(t, workdir.Init(ctx, fs, dir))
+		wd, err = workdir.New(ctx, fs, dir)
+	}
+
+	require.NoError(t, err)
+
+	return wd, fs
+}
+
+// setupTestEnv makes the cache and spec dirs default for the test. Fixtures use unix paths, so the test is skipped
+// on Windows.
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("skip for Windows")
+	}
+
+	t.Setenv(workdir.EnvCacheDir, "")
+	t.Setenv(workdir.EnvSpecDir, "")
+}
+
+// fakeRuntime is a `go` runtime that keeps installed modules in memory. Versions holds published versions of module
+// paths in ascending order.
+type fakeRuntime struct {
+	versions map[string][]string
+
+	mu        sync.Mutex
+	installed []string
+}
+
+func newFakeRuntime(versions map[string][]string) *fakeRuntime {
+	return &fakeRuntime{versions: versions}
+}
+
+// Installed returns installed modules in sorted order.
+func (r *fakeRuntime) Installed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Sorted(slices.Values(r.installed))
+}
+
+func (r *fakeRuntime) isInstalled(module string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Contains(r.installed, module)
+}
+
+func (r *fakeRuntime) Parse(_ context.Context, str string) (string, error) {
+	if _, _, ok := strings.Cut(str, "@"); !ok {
+		return "", fmt.Errorf("module (%s) has no version", str)
+	}
+
+	return str, nil
+}
+
+func (r *fakeRuntime) GetModule(_ context.Context, module string) (*structs.ModuleInfo, error) {
+	path, ver, _ := strings.Cut(module, "@")
+	name := filepath.Base(path)
+	binDir := filepath.Join("/fake", name+"___"+ver)
+
+	return &structs.ModuleInfo{
+		Name:        name,
+		Mod:         prog.NewVer(path, ver),
+		BinDir:      binDir,
+		BinPath:     filepath.Join(binDir, name),
+		IsInstalled: r.isInstalled(module),
+	}, nil
+}
+
+func (r *fakeRuntime) Install(_ context.Context, program string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.Contains(r.installed, program) {
+		r.installed = append(r.installed, program)
+	}
+
+	return nil
+}
+
+func (r *fakeRuntime) Run(_ context.Context, program string, _ structs.RunOpts) error {
+	if !r.isInstalled(program) {
+		return structs.ErrToolNotInstalled
+	}
+
+	return nil
+}
+
+func (r *fakeRuntime) GetLatest(_ context.Context, module string, _ time.Duration) (string, bool, error) {
+	path, ver, _ := strings.Cut(module, "@")
+	versions := r.versions[path]
+	if len(versions) == 0 {
+		return module, false, nil
+	}
+
+	latest := versions[len(versions)-1]
+
+	return path + "@" + latest, latest != ver, nil
+}
+
+func (r *fakeRuntime) GetReleaseNotes(context.Context, string, string) ([]structs.ReleaseNote, error) {
+	return nil, nil
+}
+
+func (r *fakeRuntime) Remove(_ context.Context, tool structs.Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.installed = slices.DeleteFunc(r.installed, func(module string) bool { return module == tool.Module })
+
+	return nil
+}
+
+func (r *fakeRuntime) Version() string {
+	return "go"
+}
@@ -0,0 +1,200 @@
+package workdir
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/kazhuravlev/toolset/internal/fsh"
+	"github.com/kazhuravlev/toolset/internal/specfile"
+	"github.com/kazhuravlev/toolset/internal/workdir/migrations"
+	"github.com/kazhuravlev/toolset/internal/workdir/structs"
+	"github.com/spf13/afero"
+)
+
+// workspaceParent is a workspace root that has the project as a member.
+type workspaceParent struct {
+	rootDir string
+	// source is a relative path from member to the spec of root. It is used as a source of inherited tools.
+	source string
+	// tools are locked tools of the root.
+	tools structs.Tools
+}
+
+// findWorkspaceParent checks the nearest spec in parent dirs. Returns nil when there is no such spec, or it is not
+// a workspace, or the project is not its member.
+func findWorkspaceParent(ctx context.Context, fs fsh.FS, projectDir string) (*workspaceParent, error) {
+	specDir := getSpecDir()
+	for dir := filepath.Dir(projectDir); filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		filename, ok := findSpecFile(fs, filepath.Join(dir, specDir))
+		if !ok {
+			continue
+		}
+
+		specJSON, err := specfile.ReadJSON(ctx, fs, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		spec, _, err := migrations.Spec(specJSON)
+		if err != nil {
+			return nil, fmt.Errorf("read spec (%s): %w", filename, err)
+		}
+
+		if spec.Workspace == nil || !isWorkspaceMember(*spec.Workspace, dir, projectDir) {
+			return nil, nil
+		}
+
+		res := workspaceParent{rootDir: dir, tools: make(structs.Tools, 0)}
+		if res.source, err = filepath.Rel(projectDir, filename); err != nil {
+			return nil, fmt.Errorf("relative path to workspace spec: %w", err)
+		}
+
+		// NOTE: root that was never synced has no lock. Members inherit nothing in this case.
+		lockFilename := filepath.Join(filepath.Dir(filename), lockFilename)
+		if fsh.IsExists(fs, lockFilename) {
+			lockJSON, err := specfile.ReadJSON(ctx, fs, lockFilename)
+			if err != nil {
+				return nil, err
+			}
+
+			lock, _, err := migrations.Lock(lockJSON)
+			if err != nil {
+				return nil, fmt.Errorf("read lock (%s): %w", lockFilename, err)
+			}
+
+			res.tools = lock.Tools
+		}
+
+		return &res, nil
+	}
+
+	return nil, nil
+}
+
+func isWorkspaceMember(ws structs.Workspace, rootDir, dir string) bool {
+	for _, pattern := range ws.Members {
+		if ok, _ := filepath.Match(filepath.Join(rootDir, pattern), dir); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveLock rebuilds tools of lock from spec and adds tools that are inherited from the workspace root. Tools of
+// member spec (and its includes) have a priority over inherited ones. Conflict policy of profile overrides the spec one.
+func (c *Workdir) resolveLock(lock *structs.Lock) ([]structs.Conflict, error) {
+	spec := c.spec
+	if c.profile.ConflictPolicy != "" {
+		override := *c.spec
+		override.ConflictPolicy = c.profile.ConflictPolicy
+		spec = &override
+	}
+
+	conflicts, err := lock.FromSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.parent == nil {
+		return conflicts, nil
+	}
+
+	for _, tool := range c.parent.tools {
+		if slices.ContainsFunc(lock.Tools, tool.IsSame) {
+			continue
+		}
+
+		tool.Source = c.parent.source
+		lock.Tools = append(lock.Tools, tool)
+	}
+
+	return conflicts, nil
+}
+
+// ProjectRootDir returns a dir of the project spec.
+func (c *Workdir) ProjectRootDir() string {
+	return c.locations.ProjectRootDir
+}
+
+// WorkspaceRoot returns a workdir of the workspace root. Returns c when the project is not a workspace member.
+func (c *Workdir) WorkspaceRoot(ctx context.Context) (*Workdir, error) {
+	if c.parent == nil {
+		return c, nil
+	}
+
+	root, err := c.open(ctx, c.parent.rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return root.WorkspaceRoot(ctx)
+}
+
+// Members returns workdirs of all workspace members, including members of nested workspaces. Members are opened with
+// settings of c, so they should be read after the root was synced.
+func (c *Workdir) Members(ctx context.Context) ([]*Workdir, error) {
+	if c.spec.Workspace == nil {
+		return nil, nil
+	}
+
+	var res []*Workdir
+	seen := make(map[string]bool)
+	for _, pattern := range c.spec.Workspace.Members {
+		dirs, err := afero.Glob(c.fs, filepath.Join(c.locations.ProjectRootDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("find members (%s): %w", pattern, err)
+		}
+
+		for _, dir := range dirs {
+			if seen[dir] {
+				continue
+			}
+
+			if _, ok := findSpecFile(c.fs, filepath.Join(dir, getSpecDir())); !ok {
+				continue
+			}
+
+			seen[dir] = true
+
+			member, err := c.open(ctx, dir)
+			if err != nil {
+				return nil, err
+			}
+
+			nested, err := member.Members(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, member)
+			res = append(res, nested...)
+		}
+	}
+
+	return res, nil
+}
+
+// open returns a workdir of another project with dry-run, offline and profile settings of c. Plan and runtimes are
+// shared, runtimes depend only on fs and the cache dir. Profile is selected only when the project has it.
+func (c *Workdir) open(ctx context.Context, dir string) (*Workdir, error) {
+	wd, err := New(ctx, c.fs, dir)
+	if err != nil {
+		return nil, fmt.Errorf("open workspace project (%s): %w", dir, err)
+	}
+
+	wd.SetDryRun(c.dryRun)
+	wd.SetOffline(c.offline)
+	wd.plan = c.plan
+	wd.runtimes = c.runtimes
+
+	if _, ok := wd.spec.Profiles[c.profileName]; ok {
+		if err := wd.SetProfile(c.profileName); err != nil {
+			return nil, err
+		}
+	}
+
+	return wd, nil
+}